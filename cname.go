@@ -0,0 +1,103 @@
+package main
+
+import (
+	"domain-tool-updater/database"
+	"domain-tool-updater/dnsquery"
+	"domain-tool-updater/events"
+	"domain-tool-updater/models"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// describeCnameChain renders a stored chain for notifications.
+func describeCnameChain(chain models.CnameChain) string {
+	if chain.Chain == "" {
+		return "no CNAME"
+	}
+	return chain.Chain
+}
+
+// checkSubdomains follows the CNAME chain of every subdomain configured for
+// the domain and stores it. A chain ending in NXDOMAIN raises a dangling
+// CNAME alert on every run until it is fixed, and a chain that changed since
+// the previous run raises a change event. Subdomains seen for the first time
+// only record their chain.
+func checkSubdomains(observer *Observer, resolver *dnsquery.Resolver, domain models.DomainInfo) {
+	subdomains, err := database.GetSubdomains(domain.Name)
+	if err != nil {
+		log.Printf("ERROR: Getting monitored subdomains for domain %s: %v", domain.Name, err)
+		return
+	}
+	if len(subdomains) == 0 {
+		return
+	}
+
+	chains, err := database.GetCnameChains(domain.Name)
+	if err != nil {
+		log.Printf("ERROR: Getting stored CNAME chains for domain %s: %v", domain.Name, err)
+		return
+	}
+	stored := map[string]models.CnameChain{}
+	for _, chain := range chains {
+		stored[chain.Subdomain] = chain
+	}
+
+	for _, subdomain := range subdomains {
+		resolved, err := resolver.ResolveCNAMEChain(subdomain)
+		if err != nil {
+			log.Printf("ERROR: CNAME lookup failed for %s, keeping stored chain: %v", subdomain, err)
+			continue
+		}
+
+		chain := models.CnameChain{
+			Domain:    domain.Name,
+			Subdomain: subdomain,
+			Issues:    strings.Join(resolved.Issues, "\n"),
+			LastCheck: time.Now(),
+		}
+		if len(resolved.Targets) > 0 {
+			chain.Chain = resolved.String()
+		}
+
+		if err := database.UpsertCnameChain(chain); err != nil {
+			log.Printf("Error trying to store CNAME chain for domain %s subdomain %s: %v", domain.Name, subdomain, err)
+		}
+
+		if resolved.Dangling {
+			event := events.Event{
+				EventType:   events.EventTypeDanglingCname,
+				EventAction: events.EventActionAlert,
+				ExecuteTime: time.Now(),
+				DomainInfo:  domain,
+				Details:     fmt.Sprintf("Subdomain %s: %s\n%s", subdomain, chain.Chain, chain.Issues),
+			}
+			observer.Notify(event)
+			log.Printf("Dangling CNAME detected for domain %s: %s", domain.Name, chain.Chain)
+		} else if chain.Issues != "" {
+			log.Printf("WARNING: CNAME issues for %s: %s", subdomain, strings.ReplaceAll(chain.Issues, "\n", "; "))
+		}
+
+		previous, known := stored[subdomain]
+		if known && previous.Chain == chain.Chain && previous.Issues == chain.Issues {
+			continue
+		}
+		if err := database.InsertCnameHistory(chain); err != nil {
+			log.Printf("Error trying to insert CNAME history for domain %s subdomain %s: %v", domain.Name, subdomain, err)
+		}
+		if !known || previous.Chain == chain.Chain {
+			continue
+		}
+
+		event := events.Event{
+			EventType:   events.EventTypeCnameChain,
+			EventAction: events.EventActionChange,
+			ExecuteTime: time.Now(),
+			DomainInfo:  domain,
+			Details:     fmt.Sprintf("Subdomain %s: CNAME chain changed\nOld: %s\nNew: %s", subdomain, describeCnameChain(previous), describeCnameChain(chain)),
+		}
+		observer.Notify(event)
+		log.Printf("CNAME chain change detected for domain %s: %s", domain.Name, event.Details)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"domain-tool-updater/models"
 	"fmt"
 	"log"
+	"strings"
 
 	_ "github.com/lib/pq"
 )
@@ -33,10 +34,73 @@ func Close() {
 	}
 }
 
-func GetDomainInfo(domainName string) (*models.DomainInfo, error) {
-	query := "SELECT name, registrar, state, tier, transfer_to, last_check, spf, dmarc, nameservers, status, whois FROM domain_info WHERE name = $1"
-	var domain models.DomainInfo
-	err := db.QueryRow(query, domainName).Scan(
+// addedColumn is a column added after the original schema, read into the
+// DomainInfo field returned by field, which is a *string or an *int.
+type addedColumn struct {
+	name  string
+	field func(d *models.DomainInfo) interface{}
+}
+
+// addedColumns lists the columns added after the original schema, in the
+// order scanDomainInfo reads them after the original ones. Rows written
+// before a column existed hold NULL there, which is reported through
+// DomainInfo.Unrecorded rather than read as an empty value.
+var addedColumns = []addedColumn{
+	{"dnssec", func(d *models.DomainInfo) interface{} { return &d.Dnssec }},
+	{"mx", func(d *models.DomainInfo) interface{} { return &d.Mx }},
+	{"apex_addresses", func(d *models.DomainInfo) interface{} { return &d.ApexAddresses }},
+	{"www_addresses", func(d *models.DomainInfo) interface{} { return &d.WwwAddresses }},
+	{"caa", func(d *models.DomainInfo) interface{} { return &d.Caa }},
+	{"spf_lookups", func(d *models.DomainInfo) interface{} { return &d.SpfLookups }},
+	{"spf_issues", func(d *models.DomainInfo) interface{} { return &d.SpfIssues }},
+	{"dmarc_issues", func(d *models.DomainInfo) interface{} { return &d.DmarcIssues }},
+	{"mta_sts_id", func(d *models.DomainInfo) interface{} { return &d.MtaStsId }},
+	{"mta_sts_mode", func(d *models.DomainInfo) interface{} { return &d.MtaStsMode }},
+	{"mta_sts_mx", func(d *models.DomainInfo) interface{} { return &d.MtaStsMx }},
+	{"mta_sts_issues", func(d *models.DomainInfo) interface{} { return &d.MtaStsIssues }},
+	{"tls_rpt", func(d *models.DomainInfo) interface{} { return &d.TlsRpt }},
+	{"bimi", func(d *models.DomainInfo) interface{} { return &d.Bimi }},
+	{"bimi_issues", func(d *models.DomainInfo) interface{} { return &d.BimiIssues }},
+	{"soa_mname", func(d *models.DomainInfo) interface{} { return &d.SoaMname }},
+	{"soa_rname", func(d *models.DomainInfo) interface{} { return &d.SoaRname }},
+	{"soa_serial", func(d *models.DomainInfo) interface{} { return &d.SoaSerial }},
+	{"soa_refresh", func(d *models.DomainInfo) interface{} { return &d.SoaRefresh }},
+	{"soa_retry", func(d *models.DomainInfo) interface{} { return &d.SoaRetry }},
+	{"soa_expire", func(d *models.DomainInfo) interface{} { return &d.SoaExpire }},
+	{"soa_minimum", func(d *models.DomainInfo) interface{} { return &d.SoaMinimum }},
+	{"ttls", func(d *models.DomainInfo) interface{} { return &d.Ttls }},
+	{"ttl_issues", func(d *models.DomainInfo) interface{} { return &d.TtlIssues }},
+	{"axfr_exposed", func(d *models.DomainInfo) interface{} { return &d.AxfrExposed }},
+	{"resolver_disagreements", func(d *models.DomainInfo) interface{} { return &d.ResolverDisagreements }},
+	{"tcp_fallbacks", func(d *models.DomainInfo) interface{} { return &d.TcpFallbacks }},
+	{"name_ascii", func(d *models.DomainInfo) interface{} { return &d.NameAscii }},
+	{"name_unicode", func(d *models.DomainInfo) interface{} { return &d.NameUnicode }},
+	{"wildcard", func(d *models.DomainInfo) interface{} { return &d.Wildcard }},
+}
+
+// domainInfoColumns lists the columns read into models.DomainInfo, in the
+// order scanDomainInfo expects them.
+var domainInfoColumns = "name, registrar, state, tier, transfer_to, last_check, spf, dmarc, nameservers, status, whois, " +
+	addedColumnNames()
+
+func addedColumnNames() string {
+	names := make([]string, len(addedColumns))
+	for i, column := range addedColumns {
+		names[i] = column.name
+	}
+	return strings.Join(names, ", ")
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanDomainInfo reads a row selected with domainInfoColumns into domain.
+// Added columns that are NULL are left at their zero value and listed in
+// domain.Unrecorded.
+func scanDomainInfo(row rowScanner, domain *models.DomainInfo) error {
+	dest := []interface{}{
 		&domain.Name,
 		&domain.Registrar,
 		&domain.State,
@@ -48,7 +112,44 @@ func GetDomainInfo(domainName string) (*models.DomainInfo, error) {
 		&domain.Nameservers,
 		&domain.Status,
 		&domain.Whois,
-	)
+	}
+	nullable := make([]interface{}, len(addedColumns))
+	for i, column := range addedColumns {
+		switch column.field(domain).(type) {
+		case *int:
+			nullable[i] = &sql.NullInt64{}
+		default:
+			nullable[i] = &sql.NullString{}
+		}
+	}
+	if err := row.Scan(append(dest, nullable...)...); err != nil {
+		return err
+	}
+
+	for i, column := range addedColumns {
+		valid := false
+		switch value := nullable[i].(type) {
+		case *sql.NullInt64:
+			*column.field(domain).(*int) = int(value.Int64)
+			valid = value.Valid
+		case *sql.NullString:
+			*column.field(domain).(*string) = value.String
+			valid = value.Valid
+		}
+		if !valid {
+			if domain.Unrecorded == nil {
+				domain.Unrecorded = map[string]bool{}
+			}
+			domain.Unrecorded[column.name] = true
+		}
+	}
+	return nil
+}
+
+func GetDomainInfo(domainName string) (*models.DomainInfo, error) {
+	query := "SELECT " + domainInfoColumns + " FROM domain_info WHERE name = $1"
+	var domain models.DomainInfo
+	err := scanDomainInfo(db.QueryRow(query, domainName), &domain)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("no domain found with name: %s", domainName)
@@ -60,7 +161,7 @@ func GetDomainInfo(domainName string) (*models.DomainInfo, error) {
 }
 
 func GetDomainInfoAll() ([]models.DomainInfo, error) {
-	query := "SELECT name, registrar, state, tier, transfer_to, last_check, spf, dmarc, nameservers, status, whois FROM domain_info WHERE status = true"
+	query := "SELECT " + domainInfoColumns + " FROM domain_info WHERE status = true"
 	rows, err := db.Query(query)
 
 	if err != nil {
@@ -72,19 +173,7 @@ func GetDomainInfoAll() ([]models.DomainInfo, error) {
 	var domains []models.DomainInfo
 	for rows.Next() {
 		var domain models.DomainInfo
-		if err := rows.Scan(
-			&domain.Name,
-			&domain.Registrar,
-			&domain.State,
-			&domain.Tier,
-			&domain.TransferTo,
-			&domain.LastCheck,
-			&domain.Spf,
-			&domain.Dmarc,
-			&domain.Nameservers,
-			&domain.Status,
-			&domain.Whois,
-		); err != nil {
+		if err := scanDomainInfo(rows, &domain); err != nil {
 
 			return nil, err
 		}
@@ -98,22 +187,11 @@ func GetDomainInfoAll() ([]models.DomainInfo, error) {
 	return domains, nil
 }
 
+// GetDomainInfoHistory returns the latest history entry of domainName.
 func GetDomainInfoHistory(domainName string) (*models.DomainInfo, error) {
-	query := "SELECT name, registrar, state, tier, transfer_to, last_check, spf, dmarc, nameservers, status, whois FROM domain_info_history WHERE name = $1"
+	query := "SELECT " + domainInfoColumns + " FROM domain_info_history WHERE name = $1 ORDER BY last_check DESC LIMIT 1"
 	var domain models.DomainInfo
-	err := db.QueryRow(query, domainName).Scan(
-		&domain.Name,
-		&domain.Registrar,
-		&domain.State,
-		&domain.Tier,
-		&domain.TransferTo,
-		&domain.LastCheck,
-		&domain.Spf,
-		&domain.Dmarc,
-		&domain.Nameservers,
-		&domain.Status,
-		&domain.Whois,
-	)
+	err := scanDomainInfo(db.QueryRow(query, domainName), &domain)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("no domain found with name: %s", domainName)
@@ -148,8 +226,244 @@ func UpdateWhois(domainName string, whois string) error {
 	return err
 }
 
+func UpdateDNSSEC(domainName string, dnssec string) error {
+	query := "UPDATE domain_info SET dnssec = $1, last_check=NOW() WHERE name = $2"
+	_, err := db.Exec(query, dnssec, domainName)
+	return err
+}
+
+func UpdateMX(domainName string, mxRecords string) error {
+	query := "UPDATE domain_info SET mx = $1, last_check=NOW() WHERE name = $2"
+	_, err := db.Exec(query, mxRecords, domainName)
+	return err
+}
+
+func UpdateApexAddresses(domainName string, addresses string) error {
+	query := "UPDATE domain_info SET apex_addresses = $1, last_check=NOW() WHERE name = $2"
+	_, err := db.Exec(query, addresses, domainName)
+	return err
+}
+
+func UpdateWwwAddresses(domainName string, addresses string) error {
+	query := "UPDATE domain_info SET www_addresses = $1, last_check=NOW() WHERE name = $2"
+	_, err := db.Exec(query, addresses, domainName)
+	return err
+}
+
+func UpdateCAA(domainName string, issuers string) error {
+	query := "UPDATE domain_info SET caa = $1, last_check=NOW() WHERE name = $2"
+	_, err := db.Exec(query, issuers, domainName)
+	return err
+}
+
+func UpdateSPFLookups(domainName string, lookups int) error {
+	query := "UPDATE domain_info SET spf_lookups = $1, last_check=NOW() WHERE name = $2"
+	_, err := db.Exec(query, lookups, domainName)
+	return err
+}
+
+func UpdateSPFIssues(domainName string, issues string) error {
+	query := "UPDATE domain_info SET spf_issues = $1, last_check=NOW() WHERE name = $2"
+	_, err := db.Exec(query, issues, domainName)
+	return err
+}
+
+func UpdateDMARCIssues(domainName string, issues string) error {
+	query := "UPDATE domain_info SET dmarc_issues = $1, last_check=NOW() WHERE name = $2"
+	_, err := db.Exec(query, issues, domainName)
+	return err
+}
+
+func UpdateMTASTS(domainName string, id string, mode string, mx string, issues string) error {
+	query := "UPDATE domain_info SET mta_sts_id = $1, mta_sts_mode = $2, mta_sts_mx = $3, mta_sts_issues = $4, last_check=NOW() WHERE name = $5"
+	_, err := db.Exec(query, id, mode, mx, issues, domainName)
+	return err
+}
+
+func UpdateTLSRPT(domainName string, record string) error {
+	query := "UPDATE domain_info SET tls_rpt = $1, last_check=NOW() WHERE name = $2"
+	_, err := db.Exec(query, record, domainName)
+	return err
+}
+
+func UpdateBIMI(domainName string, bimi string) error {
+	query := "UPDATE domain_info SET bimi = $1, last_check=NOW() WHERE name = $2"
+	_, err := db.Exec(query, bimi, domainName)
+	return err
+}
+
+func UpdateBIMIIssues(domainName string, issues string) error {
+	query := "UPDATE domain_info SET bimi_issues = $1, last_check=NOW() WHERE name = $2"
+	_, err := db.Exec(query, issues, domainName)
+	return err
+}
+
+func UpdateSOA(domainName string, mname string, rname string, serial int, refresh int, retry int, expire int, minimum int) error {
+	query := "UPDATE domain_info SET soa_mname = $1, soa_rname = $2, soa_serial = $3, soa_refresh = $4, soa_retry = $5, soa_expire = $6, soa_minimum = $7, last_check=NOW() WHERE name = $8"
+	_, err := db.Exec(query, mname, rname, serial, refresh, retry, expire, minimum, domainName)
+	return err
+}
+
+func UpdateTTLs(domainName string, ttls string, issues string) error {
+	query := "UPDATE domain_info SET ttls = $1, ttl_issues = $2, last_check=NOW() WHERE name = $3"
+	_, err := db.Exec(query, ttls, issues, domainName)
+	return err
+}
+
+func UpdateAXFRExposed(domainName string, nameservers string) error {
+	query := "UPDATE domain_info SET axfr_exposed = $1, last_check=NOW() WHERE name = $2"
+	_, err := db.Exec(query, nameservers, domainName)
+	return err
+}
+
+func UpdateResolverDisagreements(domainName string, disagreements string) error {
+	query := "UPDATE domain_info SET resolver_disagreements = $1, last_check=NOW() WHERE name = $2"
+	_, err := db.Exec(query, disagreements, domainName)
+	return err
+}
+
+func UpdateTCPFallbacks(domainName string, fallbacks string) error {
+	query := "UPDATE domain_info SET tcp_fallbacks = $1, last_check=NOW() WHERE name = $2"
+	_, err := db.Exec(query, fallbacks, domainName)
+	return err
+}
+
+// UpdateDomainNames stores the ASCII and Unicode forms of a domain name.
+func UpdateDomainNames(domainName string, nameAscii string, nameUnicode string) error {
+	query := "UPDATE domain_info SET name_ascii = $1, name_unicode = $2 WHERE name = $3"
+	_, err := db.Exec(query, nameAscii, nameUnicode, domainName)
+	return err
+}
+
+func UpdateWildcard(domainName string, wildcard string) error {
+	query := "UPDATE domain_info SET wildcard = $1, last_check=NOW() WHERE name = $2"
+	_, err := db.Exec(query, wildcard, domainName)
+	return err
+}
+
 func InsertDomainHistory(domain models.DomainInfo) error {
-	_, err := db.Exec("INSERT INTO domain_info_history (name, registrar, state, tier, transfer_to, last_check, spf, dmarc, nameservers, status, whois) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)",
-		domain.Name, domain.Registrar, domain.State, domain.Tier, domain.TransferTo, domain.LastCheck, domain.Spf, domain.Dmarc, domain.Nameservers, true, domain.Whois)
+	_, err := db.Exec("INSERT INTO domain_info_history (name, registrar, state, tier, transfer_to, last_check, spf, dmarc, nameservers, status, whois, dnssec, mx, apex_addresses, www_addresses, caa, spf_lookups, spf_issues, dmarc_issues, mta_sts_id, mta_sts_mode, mta_sts_mx, mta_sts_issues, tls_rpt, bimi, bimi_issues, soa_mname, soa_rname, soa_serial, soa_refresh, soa_retry, soa_expire, soa_minimum, ttls, ttl_issues, axfr_exposed, resolver_disagreements, tcp_fallbacks, name_ascii, name_unicode, wildcard) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27, $28, $29, $30, $31, $32, $33, $34, $35, $36, $37, $38, $39, $40, $41)",
+		domain.Name, domain.Registrar, domain.State, domain.Tier, domain.TransferTo, domain.LastCheck, domain.Spf, domain.Dmarc, domain.Nameservers, true, domain.Whois, domain.Dnssec, domain.Mx, domain.ApexAddresses, domain.WwwAddresses, domain.Caa, domain.SpfLookups, domain.SpfIssues, domain.DmarcIssues, domain.MtaStsId, domain.MtaStsMode, domain.MtaStsMx, domain.MtaStsIssues, domain.TlsRpt, domain.Bimi, domain.BimiIssues, domain.SoaMname, domain.SoaRname, domain.SoaSerial, domain.SoaRefresh, domain.SoaRetry, domain.SoaExpire, domain.SoaMinimum, domain.Ttls, domain.TtlIssues, domain.AxfrExposed, domain.ResolverDisagreements, domain.TcpFallbacks, domain.NameAscii, domain.NameUnicode, domain.Wildcard)
+	return err
+}
+
+// GetDKIMSelectors returns the DKIM selectors configured for a domain.
+func GetDKIMSelectors(domainName string) ([]string, error) {
+	rows, err := db.Query("SELECT selector FROM domain_dkim_selectors WHERE domain_name = $1 ORDER BY selector", domainName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var selectors []string
+	for rows.Next() {
+		var selector string
+		if err := rows.Scan(&selector); err != nil {
+			return nil, err
+		}
+		selectors = append(selectors, selector)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return selectors, nil
+}
+
+// GetDKIMKeys returns the DKIM keys stored for a domain by the previous run.
+func GetDKIMKeys(domainName string) ([]models.DkimKey, error) {
+	rows, err := db.Query("SELECT domain_name, selector, record, key_type, key_bits, last_check FROM domain_dkim WHERE domain_name = $1", domainName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []models.DkimKey
+	for rows.Next() {
+		var key models.DkimKey
+		if err := rows.Scan(&key.Domain, &key.Selector, &key.Record, &key.KeyType, &key.KeyBits, &key.LastCheck); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// UpsertDKIMKey stores the current DKIM key of a selector.
+func UpsertDKIMKey(key models.DkimKey) error {
+	_, err := db.Exec(`INSERT INTO domain_dkim (domain_name, selector, record, key_type, key_bits, last_check) VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (domain_name, selector) DO UPDATE SET record = EXCLUDED.record, key_type = EXCLUDED.key_type, key_bits = EXCLUDED.key_bits, last_check = EXCLUDED.last_check`,
+		key.Domain, key.Selector, key.Record, key.KeyType, key.KeyBits, key.LastCheck)
+	return err
+}
+
+func InsertDKIMHistory(key models.DkimKey) error {
+	_, err := db.Exec("INSERT INTO domain_dkim_history (domain_name, selector, record, key_type, key_bits, last_check) VALUES ($1, $2, $3, $4, $5, $6)",
+		key.Domain, key.Selector, key.Record, key.KeyType, key.KeyBits, key.LastCheck)
+	return err
+}
+
+// GetSubdomains returns the subdomains monitored for CNAME takeovers.
+func GetSubdomains(domainName string) ([]string, error) {
+	rows, err := db.Query("SELECT subdomain FROM domain_subdomains WHERE domain_name = $1 ORDER BY subdomain", domainName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subdomains []string
+	for rows.Next() {
+		var subdomain string
+		if err := rows.Scan(&subdomain); err != nil {
+			return nil, err
+		}
+		subdomains = append(subdomains, subdomain)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return subdomains, nil
+}
+
+// GetCnameChains returns the CNAME chains stored for a domain by the
+// previous run.
+func GetCnameChains(domainName string) ([]models.CnameChain, error) {
+	rows, err := db.Query("SELECT domain_name, subdomain, chain, issues, last_check FROM domain_cname WHERE domain_name = $1", domainName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var chains []models.CnameChain
+	for rows.Next() {
+		var chain models.CnameChain
+		if err := rows.Scan(&chain.Domain, &chain.Subdomain, &chain.Chain, &chain.Issues, &chain.LastCheck); err != nil {
+			return nil, err
+		}
+		chains = append(chains, chain)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return chains, nil
+}
+
+// UpsertCnameChain stores the current CNAME chain of a subdomain.
+func UpsertCnameChain(chain models.CnameChain) error {
+	_, err := db.Exec(`INSERT INTO domain_cname (domain_name, subdomain, chain, issues, last_check) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (domain_name, subdomain) DO UPDATE SET chain = EXCLUDED.chain, issues = EXCLUDED.issues, last_check = EXCLUDED.last_check`,
+		chain.Domain, chain.Subdomain, chain.Chain, chain.Issues, chain.LastCheck)
+	return err
+}
+
+func InsertCnameHistory(chain models.CnameChain) error {
+	_, err := db.Exec("INSERT INTO domain_cname_history (domain_name, subdomain, chain, issues, last_check) VALUES ($1, $2, $3, $4, $5)",
+		chain.Domain, chain.Subdomain, chain.Chain, chain.Issues, chain.LastCheck)
 	return err
 }
@@ -0,0 +1,53 @@
+package database
+
+import (
+	"database/sql"
+	"domain-tool-updater/models"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeRow scans fixed values, nil standing for NULL.
+type fakeRow []interface{}
+
+func (f fakeRow) Scan(dest ...interface{}) error {
+	for i, value := range f {
+		if scanner, ok := dest[i].(sql.Scanner); ok {
+			if err := scanner.Scan(value); err != nil {
+				return err
+			}
+			continue
+		}
+		reflect.ValueOf(dest[i]).Elem().Set(reflect.ValueOf(value))
+	}
+	return nil
+}
+
+func TestScanDomainInfo_UnrecordedColumns(t *testing.T) {
+	row := fakeRow{"example.com", "registrar", "active", "gold", "", time.Now(), "v=spf1 -all", "v=DMARC1; p=reject", "ns1.example.com.", true, ""}
+	for _, column := range addedColumns {
+		switch column.name {
+		case "dnssec":
+			row = append(row, "secure")
+		case "spf_lookups":
+			row = append(row, int64(3))
+		default:
+			row = append(row, nil)
+		}
+	}
+
+	var domain models.DomainInfo
+	if err := scanDomainInfo(row, &domain); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if domain.Dnssec != "secure" || domain.SpfLookups != 3 {
+		t.Fatalf("expected recorded values, got %q and %d", domain.Dnssec, domain.SpfLookups)
+	}
+	if !domain.Recorded("dnssec") || !domain.Recorded("spf_lookups") {
+		t.Fatalf("expected dnssec and spf_lookups to be recorded, got %v", domain.Unrecorded)
+	}
+	if domain.Recorded("caa") || domain.Recorded("wildcard") || len(domain.Unrecorded) != len(addedColumns)-2 {
+		t.Fatalf("expected the NULL columns to be unrecorded, got %v", domain.Unrecorded)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"domain-tool-updater/database"
+	"domain-tool-updater/dnsquery"
+	"domain-tool-updater/events"
+	"domain-tool-updater/models"
+	"fmt"
+	"log"
+	"time"
+)
+
+// describeDKIMKey summarizes a stored key for notifications, e.g. "rsa 2048".
+func describeDKIMKey(key models.DkimKey) string {
+	if key.KeyType == "" {
+		return "unparsed key"
+	}
+	if key.KeyBits == 0 {
+		return key.KeyType + " revoked"
+	}
+	return fmt.Sprintf("%s %d", key.KeyType, key.KeyBits)
+}
+
+// checkDKIM looks up the DKIM key of every selector configured for the
+// domain, stores it and raises an event for each key that was added, removed
+// or rotated since the previous run. Selectors seen for the first time only
+// record their key.
+func checkDKIM(observer *Observer, resolver *dnsquery.Resolver, domain models.DomainInfo) {
+	selectors, err := database.GetDKIMSelectors(domain.Name)
+	if err != nil {
+		log.Printf("ERROR: Getting DKIM selectors for domain %s: %v", domain.Name, err)
+		return
+	}
+	if len(selectors) == 0 {
+		return
+	}
+
+	keys, err := database.GetDKIMKeys(domain.Name)
+	if err != nil {
+		log.Printf("ERROR: Getting stored DKIM keys for domain %s: %v", domain.Name, err)
+		return
+	}
+	stored := map[string]models.DkimKey{}
+	for _, key := range keys {
+		stored[key.Selector] = key
+	}
+
+	for _, selector := range selectors {
+		record, err := resolver.GetDKIMRecord(domain.Name, selector)
+		if err != nil && !dnsquery.IsNotFound(err) {
+			log.Printf("ERROR: DKIM lookup failed for %s selector %s, keeping stored key: %v", domain.Name, selector, err)
+			continue
+		}
+
+		key := models.DkimKey{Domain: domain.Name, Selector: selector, Record: record, LastCheck: time.Now()}
+		if record != "" {
+			parsed, err := dnsquery.ParseDKIMRecord(record)
+			if err != nil {
+				log.Printf("WARNING: Invalid DKIM record for %s selector %s: %v", domain.Name, selector, err)
+			} else {
+				key.KeyType = parsed.KeyType
+				key.KeyBits = parsed.Bits
+			}
+		}
+
+		if err := database.UpsertDKIMKey(key); err != nil {
+			log.Printf("Error trying to store DKIM key for domain %s selector %s: %v", domain.Name, selector, err)
+		}
+
+		previous, known := stored[selector]
+		if known && previous.Record == key.Record {
+			continue
+		}
+		if err := database.InsertDKIMHistory(key); err != nil {
+			log.Printf("Error trying to insert DKIM history for domain %s selector %s: %v", domain.Name, selector, err)
+		}
+		if !known {
+			continue
+		}
+
+		event := events.Event{
+			ExecuteTime: time.Now(),
+			DomainInfo:  domain,
+		}
+		switch {
+		case previous.Record == "":
+			event.EventType = events.EventTypeDkimAdded
+			event.EventAction = events.EventActionInsert
+			event.Details = fmt.Sprintf("Selector %s: key added (%s)\n%s", selector, describeDKIMKey(key), key.Record)
+		case key.Record == "":
+			event.EventType = events.EventTypeDkimRemoved
+			event.EventAction = events.EventActionDelete
+			event.Details = fmt.Sprintf("Selector %s: key removed (was %s)\n%s", selector, describeDKIMKey(previous), previous.Record)
+		default:
+			event.EventType = events.EventTypeDkimRotated
+			event.EventAction = events.EventActionChange
+			event.Details = fmt.Sprintf("Selector %s: key rotated from %s to %s\nOld: %s\nNew: %s", selector, describeDKIMKey(previous), describeDKIMKey(key), previous.Record, key.Record)
+		}
+		observer.Notify(event)
+		log.Printf("DKIM change detected for domain %s: %s", domain.Name, event.Details)
+	}
+}
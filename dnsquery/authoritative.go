@@ -0,0 +1,226 @@
+package dnsquery
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// AuthoritativeAnswer holds what a single authoritative nameserver returned
+// for the zone apex.
+type AuthoritativeAnswer struct {
+	Nameserver string
+	Address    string
+	// Authoritative is false when any reply came back without the AA bit.
+	Authoritative bool
+	Serial        uint32
+	NS            []string
+	TXT           []string
+	DMARC         []string
+	// Err is set when the nameserver could not be resolved or queried.
+	Err error
+}
+
+// ConsistencyReport compares the answers of every authoritative nameserver
+// of a domain.
+type ConsistencyReport struct {
+	Domain  string
+	Answers []AuthoritativeAnswer
+	Issues  []string
+}
+
+// Consistent reports whether all nameservers answered authoritatively and
+// agreed with each other.
+func (c *ConsistencyReport) Consistent() bool {
+	return len(c.Issues) == 0
+}
+
+// QueryServer sends a non-recursive query for domain and qtype straight to
+// server and returns the whole reply, so callers can inspect its flags.
+func (r *Resolver) QueryServer(server, domain string, qtype uint16) (*dns.Msg, error) {
+	name, err := ToASCII(domain)
+	if err != nil {
+		return nil, err
+	}
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(name), qtype)
+	msg.RecursionDesired = false
+	msg.SetEdns0(r.udpSize, false)
+
+	var response *dns.Msg
+	for attempt := 0; attempt <= r.retries; attempt++ {
+		if err = r.ctx.Err(); err != nil {
+			break
+		}
+		response, err = r.exchangeServer(r.direct, msg, serverAddress(server))
+		if err == nil {
+			return response, nil
+		}
+	}
+	return nil, lookupError(domain, qtype, nil, err)
+}
+
+// CheckAuthoritative checks the authoritative nameservers of domain using
+// the default resolver.
+func CheckAuthoritative(domain string) (*ConsistencyReport, error) {
+	return defaultResolver.CheckAuthoritative(domain)
+}
+
+// CheckAuthoritative resolves every nameserver of domain, queries each one
+// directly for SOA, NS, TXT and DMARC and reports lame delegations and
+// servers that disagree with each other.
+func (r *Resolver) CheckAuthoritative(domain string) (*ConsistencyReport, error) {
+	nameservers, err := r.GetNSRecords(domain)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(nameservers)
+
+	report := &ConsistencyReport{Domain: domain}
+	for _, nameserver := range nameservers {
+		answer := r.queryAuthoritative(nameserver, domain)
+		if answer.Err != nil {
+			report.Issues = append(report.Issues, fmt.Sprintf("lame delegation: %s did not answer: %v", nameserver, answer.Err))
+		} else if !answer.Authoritative {
+			report.Issues = append(report.Issues, fmt.Sprintf("lame delegation: %s is not authoritative for %s", nameserver, domain))
+		}
+		report.Answers = append(report.Answers, answer)
+	}
+
+	report.compare("SOA serial", func(a AuthoritativeAnswer) string { return fmt.Sprint(a.Serial) })
+	report.compare("NS set", func(a AuthoritativeAnswer) string { return strings.Join(a.NS, ", ") })
+	report.compare("TXT set", func(a AuthoritativeAnswer) string { return strings.Join(a.TXT, " | ") })
+	report.compare("DMARC record", func(a AuthoritativeAnswer) string { return strings.Join(a.DMARC, " | ") })
+	return report, nil
+}
+
+// compare adds an issue when the servers that answered authoritatively do
+// not all return the same value.
+func (c *ConsistencyReport) compare(what string, value func(AuthoritativeAnswer) string) {
+	var (
+		values   []string
+		distinct = map[string]bool{}
+	)
+	for _, answer := range c.Answers {
+		if answer.Err != nil || !answer.Authoritative {
+			continue
+		}
+		v := value(answer)
+		distinct[v] = true
+		values = append(values, fmt.Sprintf("%s=%q", answer.Nameserver, v))
+	}
+	if len(distinct) > 1 {
+		c.Issues = append(c.Issues, fmt.Sprintf("%s differs between nameservers: %s", what, strings.Join(values, ", ")))
+	}
+}
+
+// queryAuthoritative collects the apex records of domain from a single
+// nameserver.
+func (r *Resolver) queryAuthoritative(nameserver, domain string) AuthoritativeAnswer {
+	answer := AuthoritativeAnswer{Nameserver: nameserver, Authoritative: true}
+
+	address, err := r.nameserverAddress(nameserver)
+	if err != nil {
+		answer.Err = err
+		return answer
+	}
+	answer.Address = address
+
+	soa, err := r.authoritativeRecords(&answer, domain, dns.TypeSOA)
+	if err != nil {
+		answer.Err = err
+		return answer
+	}
+	for _, record := range soa {
+		if s, ok := record.(*dns.SOA); ok {
+			answer.Serial = s.Serial
+		}
+	}
+
+	ns, err := r.authoritativeRecords(&answer, domain, dns.TypeNS)
+	if err != nil {
+		answer.Err = err
+		return answer
+	}
+	for _, record := range ns {
+		if n, ok := record.(*dns.NS); ok {
+			answer.NS = append(answer.NS, strings.ToLower(n.Ns))
+		}
+	}
+	sort.Strings(answer.NS)
+
+	txt, err := r.authoritativeRecords(&answer, domain, dns.TypeTXT)
+	if err != nil {
+		answer.Err = err
+		return answer
+	}
+	answer.TXT = txtStrings(txt)
+
+	dmarc, err := r.authoritativeRecords(&answer, "_dmarc."+domain, dns.TypeTXT)
+	if err != nil {
+		answer.Err = err
+		return answer
+	}
+	answer.DMARC = txtStrings(dmarc)
+
+	return answer
+}
+
+// nameserverAddress resolves nameserver to its first IPv4 address, falling
+// back to IPv6 for nameservers without A records.
+func (r *Resolver) nameserverAddress(nameserver string) (string, error) {
+	records, err := r.Query(nameserver, dns.TypeA)
+	if IsNotFound(err) {
+		records, err = r.Query(nameserver, dns.TypeAAAA)
+	}
+	if err != nil {
+		return "", err
+	}
+	for _, record := range records {
+		switch rr := record.(type) {
+		case *dns.A:
+			return rr.A.String(), nil
+		case *dns.AAAA:
+			return rr.AAAA.String(), nil
+		}
+	}
+	return "", lookupError(nameserver, dns.TypeA, new(dns.Msg), nil)
+}
+
+// authoritativeRecords queries the nameserver behind answer for name and
+// qtype. NXDOMAIN and empty answers are valid authoritative replies and
+// yield no records; a reply without the AA bit marks the server as lame.
+func (r *Resolver) authoritativeRecords(answer *AuthoritativeAnswer, name string, qtype uint16) ([]dns.RR, error) {
+	response, err := r.QueryServer(answer.Address, name, qtype)
+	if err != nil {
+		return nil, err
+	}
+	if response.Rcode != dns.RcodeSuccess && response.Rcode != dns.RcodeNameError {
+		return nil, lookupError(name, qtype, response, nil)
+	}
+	if !response.Authoritative {
+		answer.Authoritative = false
+	}
+
+	var records []dns.RR
+	for _, record := range response.Answer {
+		if record.Header().Rrtype == qtype {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+// txtStrings returns the sorted, concatenated strings of the TXT records.
+func txtStrings(records []dns.RR) []string {
+	var values []string
+	for _, record := range records {
+		if txt, ok := record.(*dns.TXT); ok {
+			values = append(values, strings.Join(txt.Txt, ""))
+		}
+	}
+	sort.Strings(values)
+	return values
+}
@@ -0,0 +1,78 @@
+package dnsquery
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// authoritativeResolver returns a Resolver whose recursive upstream
+// delegates example.com to ns1 (192.0.2.1) and ns2 (192.0.2.2), which
+// publish the given SOA serial and SPF record.
+func authoritativeResolver(t *testing.T, serials map[string]uint32, spf map[string]string) *Resolver {
+	t.Helper()
+	delegation := []string{
+		"example.com. 300 IN NS ns1.example.net.",
+		"example.com. 300 IN NS ns2.example.net.",
+	}
+	zones := map[string][]string{
+		"198.51.100.1": append([]string{"ns1.example.net. 300 IN A 192.0.2.1", "ns2.example.net. 300 IN A 192.0.2.2"}, delegation...),
+	}
+	for host, serial := range serials {
+		zones[host] = append([]string{
+			fmt.Sprintf("example.com. 300 IN SOA ns1.example.net. hostmaster.example.com. %d 7200 900 1209600 300", serial),
+			fmt.Sprintf("example.com. 300 IN TXT %q", spf[host]),
+		}, delegation...)
+	}
+	return zonesResolver(t, Config{Servers: []string{"198.51.100.1"}}, zones)
+}
+
+func TestCheckAuthoritative_Consistent(t *testing.T) {
+	resolver := authoritativeResolver(t,
+		map[string]uint32{"192.0.2.1": 2024010101, "192.0.2.2": 2024010101},
+		map[string]string{"192.0.2.1": "v=spf1 -all", "192.0.2.2": "v=spf1 -all"},
+	)
+
+	report, err := resolver.CheckAuthoritative("example.com")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if !report.Consistent() {
+		t.Fatalf("expected consistent report, got issues: %v", report.Issues)
+	}
+	if len(report.Answers) != 2 || report.Answers[0].Serial != 2024010101 {
+		t.Fatalf("unexpected answers: %+v", report.Answers)
+	}
+}
+
+func TestCheckAuthoritative_DetectsDivergence(t *testing.T) {
+	resolver := authoritativeResolver(t,
+		map[string]uint32{"192.0.2.1": 2024010102, "192.0.2.2": 2024010101},
+		map[string]string{"192.0.2.1": "v=spf1 include:new.example.net -all", "192.0.2.2": "v=spf1 -all"},
+	)
+
+	report, err := resolver.CheckAuthoritative("example.com")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	issues := strings.Join(report.Issues, "\n")
+	if !strings.Contains(issues, "SOA serial differs") || !strings.Contains(issues, "TXT set differs") {
+		t.Fatalf("expected serial and TXT divergence, got: %v", report.Issues)
+	}
+}
+
+func TestCheckAuthoritative_DetectsLameDelegation(t *testing.T) {
+	resolver := authoritativeResolver(t,
+		map[string]uint32{"192.0.2.1": 2024010101, "192.0.2.2": 2024010101},
+		map[string]string{"192.0.2.1": "v=spf1 -all", "192.0.2.2": "v=spf1 -all"},
+	)
+	lameServer(resolver, "192.0.2.2")
+
+	report, err := resolver.CheckAuthoritative("example.com")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(report.Issues) != 1 || !strings.Contains(report.Issues[0], "ns2.example.net. is not authoritative") {
+		t.Fatalf("expected lame delegation for ns2, got: %v", report.Issues)
+	}
+}
@@ -0,0 +1,216 @@
+package dnsquery
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// TransferRcodeError is the envelope error a Transferer reports when the
+// server answers the transfer with an rcode other than NOERROR.
+type TransferRcodeError struct {
+	Rcode int
+}
+
+func (e *TransferRcodeError) Error() string {
+	return "zone transfer answered " + dns.RcodeToString[e.Rcode]
+}
+
+// TransferAttempt is the outcome of asking one nameserver for a zone
+// transfer.
+type TransferAttempt struct {
+	Nameserver string
+	Address    string
+	// Allowed is set when the server sent the zone.
+	Allowed bool
+	// Denied is set when the server answered with an rcode other than
+	// NOERROR, such as REFUSED or NOTAUTH. An attempt that is neither
+	// allowed nor denied, such as one that timed out, leaves the exposure
+	// of the server unknown.
+	Denied bool
+	// Records is the number of records received.
+	Records int
+	// Err holds why the transfer did not happen: a refusal, a timeout or
+	// a nameserver that could not be resolved.
+	Err error
+}
+
+// TransferReport lists the zone transfer attempts against every
+// authoritative nameserver of a domain.
+type TransferReport struct {
+	Domain   string
+	Attempts []TransferAttempt
+}
+
+// Exposed returns the nameservers that allowed an unauthenticated transfer.
+func (t *TransferReport) Exposed() []string {
+	var exposed []string
+	for _, attempt := range t.Attempts {
+		if attempt.Allowed {
+			exposed = append(exposed, attempt.Nameserver)
+		}
+	}
+	return exposed
+}
+
+// ExposedKeeping returns the nameservers that allowed a transfer, plus those
+// in previous whose attempt was neither allowed nor denied, so a server that
+// did not answer keeps its previous exposure.
+func (t *TransferReport) ExposedKeeping(previous []string) []string {
+	var exposed []string
+	for _, attempt := range t.Attempts {
+		unknown := !attempt.Allowed && !attempt.Denied
+		if attempt.Allowed || (unknown && containsFold(previous, attempt.Nameserver)) {
+			exposed = append(exposed, attempt.Nameserver)
+		}
+	}
+	return exposed
+}
+
+func containsFold(names []string, name string) bool {
+	for _, candidate := range names {
+		if strings.EqualFold(candidate, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckZoneTransfer attempts an AXFR of domain against its nameservers
+// using the default resolver.
+func CheckZoneTransfer(domain string) (*TransferReport, error) {
+	return defaultResolver.CheckZoneTransfer(domain)
+}
+
+// CheckZoneTransfer attempts an unauthenticated AXFR of domain against every
+// nameserver returned by GetNSRecords. Errors are only returned when the
+// nameservers cannot be looked up; the outcome for each server is in its
+// attempt.
+func (r *Resolver) CheckZoneTransfer(domain string) (*TransferReport, error) {
+	domain, err := ToASCII(domain)
+	if err != nil {
+		return nil, err
+	}
+	nameservers, err := r.GetNSRecords(domain)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(nameservers)
+
+	report := &TransferReport{Domain: domain}
+	for _, nameserver := range nameservers {
+		report.Attempts = append(report.Attempts, r.attemptTransfer(nameserver, domain))
+	}
+	return report, nil
+}
+
+// attemptTransfer asks a single nameserver for the zone. The transfer only
+// counts as allowed when records actually arrived.
+func (r *Resolver) attemptTransfer(nameserver, domain string) TransferAttempt {
+	attempt := TransferAttempt{Nameserver: nameserver}
+	// Transfers cannot be cancelled, so at least do not start new ones
+	if err := r.ctx.Err(); err != nil {
+		attempt.Err = err
+		return attempt
+	}
+
+	address, err := r.nameserverAddress(nameserver)
+	if err != nil {
+		attempt.Err = err
+		return attempt
+	}
+	attempt.Address = address
+
+	msg := new(dns.Msg)
+	msg.SetAxfr(dns.Fqdn(domain))
+	envelopes, err := r.transfer.In(msg, serverAddress(address))
+	if err != nil {
+		attempt.Err = err
+		return attempt
+	}
+	// Drain the channel so the transfer goroutine can finish
+	for envelope := range envelopes {
+		if envelope.Error != nil {
+			attempt.Err = envelope.Error
+			continue
+		}
+		attempt.Records += len(envelope.RR)
+	}
+	attempt.Allowed = attempt.Err == nil && attempt.Records > 0
+	var rcodeErr *TransferRcodeError
+	attempt.Denied = errors.As(attempt.Err, &rcodeErr)
+	return attempt
+}
+
+// zoneTransferer is the default Transferer. Unlike dns.Transfer, which keeps
+// its closed connection after the first transfer, it dials every transfer
+// on a new connection, and it reports the rcode of a refused transfer as a
+// TransferRcodeError.
+type zoneTransferer struct {
+	timeout time.Duration
+	dial    func(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+func newZoneTransferer(timeout time.Duration) *zoneTransferer {
+	return &zoneTransferer{timeout: timeout, dial: (&net.Dialer{Timeout: timeout}).DialContext}
+}
+
+func (t *zoneTransferer) In(msg *dns.Msg, address string) (chan *dns.Envelope, error) {
+	conn, err := t.dial(context.Background(), "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	transfer := &dns.Conn{Conn: conn}
+	transfer.SetWriteDeadline(time.Now().Add(t.timeout))
+	if err := transfer.WriteMsg(msg); err != nil {
+		transfer.Close()
+		return nil, err
+	}
+
+	envelopes := make(chan *dns.Envelope)
+	go t.receive(transfer, msg, envelopes)
+	return envelopes, nil
+}
+
+// receive streams the replies of an AXFR until the closing SOA arrives.
+func (t *zoneTransferer) receive(conn *dns.Conn, msg *dns.Msg, envelopes chan *dns.Envelope) {
+	defer func() {
+		conn.Close()
+		close(envelopes)
+	}()
+
+	soas := 0
+	for {
+		conn.SetReadDeadline(time.Now().Add(t.timeout))
+		reply, err := conn.ReadMsg()
+		switch {
+		case err != nil:
+			envelopes <- &dns.Envelope{Error: err}
+			return
+		case reply.Id != msg.Id:
+			envelopes <- &dns.Envelope{Error: dns.ErrId}
+			return
+		case reply.Rcode != dns.RcodeSuccess:
+			envelopes <- &dns.Envelope{Error: &TransferRcodeError{Rcode: reply.Rcode}}
+			return
+		case soas == 0 && (len(reply.Answer) == 0 || reply.Answer[0].Header().Rrtype != dns.TypeSOA):
+			envelopes <- &dns.Envelope{Error: dns.ErrSoa}
+			return
+		}
+
+		envelopes <- &dns.Envelope{RR: reply.Answer}
+		for _, rr := range reply.Answer {
+			if rr.Header().Rrtype == dns.TypeSOA {
+				soas++
+			}
+		}
+		if soas >= 2 {
+			return
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package dnsquery
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// transferFunc adapts a function to the Transferer interface.
+type transferFunc func(msg *dns.Msg, address string) (chan *dns.Envelope, error)
+
+func (f transferFunc) In(msg *dns.Msg, address string) (chan *dns.Envelope, error) {
+	return f(msg, address)
+}
+
+func TestCheckZoneTransfer(t *testing.T) {
+	resolver := zoneResolver(t,
+		`example.com. 300 IN NS ns1.example.com.`,
+		`example.com. 300 IN NS ns2.example.com.`,
+		`example.com. 300 IN NS ns3.example.com.`,
+		`ns1.example.com. 300 IN A 192.0.2.1`,
+		`ns2.example.com. 300 IN A 192.0.2.2`,
+		`ns3.example.com. 300 IN A 192.0.2.3`,
+	)
+	resolver.transfer = transferFunc(func(msg *dns.Msg, address string) (chan *dns.Envelope, error) {
+		if msg.Question[0].Qtype != dns.TypeAXFR || msg.Question[0].Name != "example.com." {
+			t.Fatalf("unexpected transfer request %v", msg.Question[0])
+		}
+		envelopes := make(chan *dns.Envelope, 2)
+		switch address {
+		case "192.0.2.1:53":
+			soa, _ := dns.NewRR(`example.com. 300 IN SOA ns1.example.com. hostmaster.example.com. 1 7200 900 1209600 300`)
+			a, _ := dns.NewRR(`internal.example.com. 300 IN A 10.0.0.1`)
+			envelopes <- &dns.Envelope{RR: []dns.RR{soa, a, soa}}
+		case "192.0.2.2:53":
+			envelopes <- &dns.Envelope{Error: &TransferRcodeError{Rcode: dns.RcodeRefused}}
+		default:
+			close(envelopes)
+			return nil, &net.OpError{Op: "dial", Err: errors.New("connection refused")}
+		}
+		close(envelopes)
+		return envelopes, nil
+	})
+
+	report, err := resolver.CheckZoneTransfer("example.com")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if exposed := report.Exposed(); !reflect.DeepEqual(exposed, []string{"ns1.example.com."}) {
+		t.Fatalf("expected only ns1 exposed, got %v", exposed)
+	}
+	if report.Attempts[0].Records != 3 || report.Attempts[1].Err == nil || report.Attempts[2].Err == nil {
+		t.Fatalf("unexpected attempts: %+v", report.Attempts)
+	}
+	if report.Attempts[0].Denied || !report.Attempts[1].Denied || report.Attempts[2].Denied {
+		t.Fatalf("expected only the REFUSED attempt to be denied: %+v", report.Attempts)
+	}
+
+	// ns2 refused, so only the unreachable ns3 keeps its previous exposure
+	exposed := report.ExposedKeeping([]string{"ns2.example.com.", "ns3.example.com."})
+	if !reflect.DeepEqual(exposed, []string{"ns1.example.com.", "ns3.example.com."}) {
+		t.Fatalf("expected ns1 and ns3 exposed, got %v", exposed)
+	}
+}
+
+func TestCheckZoneTransfer_LocalServer(t *testing.T) {
+	// Every transfer reaches the same server: the first one is answered
+	// NOTIMP, later ones get the zone
+	var requests int32
+	handler := dns.HandlerFunc(func(w dns.ResponseWriter, request *dns.Msg) {
+		reply := new(dns.Msg)
+		if atomic.AddInt32(&requests, 1) == 1 {
+			reply.SetRcode(request, dns.RcodeNotImplemented)
+		} else {
+			soa, _ := dns.NewRR(`example.com. 300 IN SOA ns1.example.com. hostmaster.example.com. 1 7200 900 1209600 300`)
+			a, _ := dns.NewRR(`internal.example.com. 300 IN A 10.0.0.1`)
+			reply.SetReply(request)
+			reply.Answer = []dns.RR{soa, a, soa}
+		}
+		w.WriteMsg(reply)
+	})
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	server := &dns.Server{Listener: listener, Handler: handler}
+	go server.ActivateAndServe()
+	t.Cleanup(func() { server.Shutdown() })
+
+	resolver := zoneResolver(t,
+		`example.com. 300 IN NS ns1.example.com.`,
+		`example.com. 300 IN NS ns2.example.com.`,
+		`ns1.example.com. 300 IN A 192.0.2.1`,
+		`ns2.example.com. 300 IN A 192.0.2.2`,
+	)
+	transferer := newZoneTransferer(time.Second)
+	transferer.dial = func(ctx context.Context, network, address string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, listener.Addr().String())
+	}
+	resolver.transfer = transferer
+
+	report, err := resolver.CheckZoneTransfer("example.com")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if !report.Attempts[0].Denied || report.Attempts[0].Allowed {
+		t.Fatalf("expected ns1 to be denied, got %+v", report.Attempts[0])
+	}
+	if !report.Attempts[1].Allowed || report.Attempts[1].Records != 3 {
+		t.Fatalf("expected ns2 to send the zone, got %+v", report.Attempts[1])
+	}
+}
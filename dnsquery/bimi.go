@@ -0,0 +1,93 @@
+package dnsquery
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// BIMIRecord is a parsed BIMI assertion record.
+type BIMIRecord struct {
+	Raw string
+	// Location is the l= URL of the SVG logo.
+	Location string
+	// Authority is the a= URL of the Verified Mark Certificate.
+	Authority string
+	// Issues lists syntax problems and unmet prerequisites.
+	Issues []string
+}
+
+func (b *BIMIRecord) addIssue(format string, args ...interface{}) {
+	b.Issues = append(b.Issues, fmt.Sprintf(format, args...))
+}
+
+// Declined reports whether the record explicitly declines to show a logo,
+// which is done by publishing empty l= and a= tags.
+func (b *BIMIRecord) Declined() bool {
+	return b.Location == "" && b.Authority == ""
+}
+
+// ParseBIMI parses a BIMI record. Problems inside the record are reported
+// in Issues; an error is only returned when the string is not a BIMI record.
+func ParseBIMI(record string) (*BIMIRecord, error) {
+	if !strings.HasPrefix(record, "v=BIMI1") {
+		return nil, fmt.Errorf("not a BIMI record: %q", record)
+	}
+
+	tags := parseTags(record)
+	bimi := &BIMIRecord{Raw: record, Location: tags["l"], Authority: tags["a"]}
+	if _, ok := tags["l"]; !ok {
+		bimi.addIssue("missing l= tag")
+	}
+	for _, tag := range []struct{ name, value string }{{"l", bimi.Location}, {"a", bimi.Authority}} {
+		if tag.value == "" {
+			continue
+		}
+		if u, err := url.Parse(tag.value); err != nil || u.Scheme != "https" || u.Host == "" {
+			bimi.addIssue("%s= must be an https URL, got %q", tag.name, tag.value)
+		}
+	}
+	return bimi, nil
+}
+
+// CheckDMARC adds an issue when the DMARC record does not meet the BIMI
+// requirement of an enforcing policy: quarantine or reject at 100% for the
+// domain and its subdomains.
+func (b *BIMIRecord) CheckDMARC(dmarcRecord string) {
+	if b.Declined() {
+		return
+	}
+	dmarc, err := ParseDMARC(dmarcRecord)
+	if err != nil {
+		b.addIssue("BIMI requires DMARC but no DMARC record is published")
+		return
+	}
+	if dmarc.Strength() < DMARCStrengthQuarantine {
+		b.addIssue("BIMI requires an enforcing DMARC policy, found %s", dmarc.PolicyDescription())
+	}
+	// sp defaults to p, so only report it when it is weaker than p
+	if dmarc.SubdomainPolicy == "none" && dmarc.Policy != "none" {
+		b.addIssue("BIMI requires an enforcing DMARC subdomain policy, found sp=none")
+	}
+}
+
+// GetBIMIRecord fetches the default BIMI record of a domain using the
+// default resolver.
+func GetBIMIRecord(domain string) (*BIMIRecord, error) {
+	return defaultResolver.GetBIMIRecord(domain)
+}
+
+// GetBIMIRecord fetches and parses the record at default._bimi.<domain>.
+func (r *Resolver) GetBIMIRecord(domain string) (*BIMIRecord, error) {
+	records, err := r.GetTXTRecords("default._bimi." + domain)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, record := range records {
+		if strings.HasPrefix(record, "v=BIMI1") {
+			return ParseBIMI(record)
+		}
+	}
+	return nil, fmt.Errorf("no BIMI record found for %s: %w", domain, ErrNoData)
+}
@@ -0,0 +1,53 @@
+package dnsquery
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGetBIMIRecord_ParsesTags(t *testing.T) {
+	resolver := zoneResolver(t, `default._bimi.example.com. 300 IN TXT "v=BIMI1; l=https://example.com/logo.svg; a=https://example.com/vmc.pem"`)
+
+	bimi, err := resolver.GetBIMIRecord("example.com")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if bimi.Location != "https://example.com/logo.svg" || bimi.Authority != "https://example.com/vmc.pem" {
+		t.Fatalf("unexpected tags: %+v", bimi)
+	}
+	if len(bimi.Issues) != 0 {
+		t.Fatalf("unexpected issues: %v", bimi.Issues)
+	}
+}
+
+func TestParseBIMI_InvalidLocation(t *testing.T) {
+	bimi, err := ParseBIMI("v=BIMI1; l=http://example.com/logo.svg")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(bimi.Issues) != 1 || !strings.Contains(bimi.Issues[0], "https URL") {
+		t.Fatalf("expected https issue, got %v", bimi.Issues)
+	}
+}
+
+func TestBIMIRecord_CheckDMARC(t *testing.T) {
+	cases := []struct {
+		dmarc  string
+		issues int
+	}{
+		{"v=DMARC1; p=reject", 0},
+		{"v=DMARC1; p=quarantine", 0},
+		{"v=DMARC1; p=quarantine; pct=50", 1},
+		{"v=DMARC1; p=none", 1},
+		{"v=DMARC1; p=reject; sp=none", 1},
+		{"", 1},
+	}
+
+	for _, tc := range cases {
+		bimi, _ := ParseBIMI("v=BIMI1; l=https://example.com/logo.svg")
+		bimi.CheckDMARC(tc.dmarc)
+		if len(bimi.Issues) != tc.issues {
+			t.Fatalf("DMARC %q: expected %d issues, got %v", tc.dmarc, tc.issues, bimi.Issues)
+		}
+	}
+}
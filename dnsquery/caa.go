@@ -0,0 +1,101 @@
+package dnsquery
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// CAARecord is a single CAA property.
+type CAARecord struct {
+	Flag  uint8
+	Tag   string
+	Value string
+}
+
+// CAAResult is the relevant CAA record set of a domain.
+type CAAResult struct {
+	// Name is where the record set was found: the domain itself or the
+	// closest ancestor that publishes CAA.
+	Name    string
+	Records []CAARecord
+}
+
+// Issuers returns the sorted "tag value" pairs of the issue and issuewild
+// properties, e.g. "issue letsencrypt.org".
+func (c *CAAResult) Issuers() []string {
+	var issuers []string
+	for _, record := range c.Records {
+		if record.Tag == "issue" || record.Tag == "issuewild" {
+			issuers = append(issuers, record.Tag+" "+strings.TrimSpace(record.Value))
+		}
+	}
+	sort.Strings(issuers)
+	return issuers
+}
+
+// CAANone is the summary of a domain whose CAA does not restrict issuance.
+const CAANone = "none"
+
+// Restricted reports whether the record set limits which CAs may issue. Per
+// RFC 8659 a set without issue or issuewild properties, e.g. only iodef,
+// does not.
+func (c *CAAResult) Restricted() bool {
+	return len(c.Issuers()) > 0
+}
+
+// Summary renders the result for storage: the issuers, CAANone when there is
+// no CAA anywhere up the tree, or CAANone followed by the properties found
+// when none of them restricts issuance, e.g. "none (iodef at example.com)".
+func (c *CAAResult) Summary() string {
+	if c.Restricted() {
+		return strings.Join(c.Issuers(), ", ")
+	}
+	if len(c.Records) == 0 {
+		return CAANone
+	}
+	seen := map[string]bool{}
+	var tags []string
+	for _, record := range c.Records {
+		if !seen[record.Tag] {
+			seen[record.Tag] = true
+			tags = append(tags, record.Tag)
+		}
+	}
+	sort.Strings(tags)
+	return CAANone + " (" + strings.Join(tags, ", ") + " at " + c.Name + ")"
+}
+
+// GetCAARecords fetches the relevant CAA record set of a domain using the
+// default resolver.
+func GetCAARecords(domain string) (*CAAResult, error) {
+	return defaultResolver.GetCAARecords(domain)
+}
+
+// GetCAARecords fetches the relevant CAA record set of a domain. Following
+// RFC 8659 section 3 it climbs from the domain towards the top level domain
+// and returns the first non-empty set. A domain without CAA anywhere up the
+// tree yields a result with no records.
+func (r *Resolver) GetCAARecords(domain string) (*CAAResult, error) {
+	labels := dns.SplitDomainName(domain)
+	for i := range labels {
+		name := strings.Join(labels[i:], ".")
+		records, err := r.Query(name, dns.TypeCAA)
+		if IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		result := &CAAResult{Name: name}
+		for _, record := range records {
+			if caa, ok := record.(*dns.CAA); ok {
+				result.Records = append(result.Records, CAARecord{Flag: caa.Flag, Tag: strings.ToLower(caa.Tag), Value: caa.Value})
+			}
+		}
+		return result, nil
+	}
+	return &CAAResult{}, nil
+}
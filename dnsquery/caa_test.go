@@ -0,0 +1,61 @@
+package dnsquery
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGetCAARecords_ClimbsToParent(t *testing.T) {
+	resolver := zoneResolver(t,
+		`example.com. 300 IN CAA 0 issue "letsencrypt.org"`,
+		`example.com. 300 IN CAA 0 issue "digicert.com"`,
+	)
+	queried := queriedNames(resolver)
+
+	result, err := resolver.GetCAARecords("shop.eu.example.com")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if result.Name != "example.com" {
+		t.Fatalf("expected CAA from example.com, got %q", result.Name)
+	}
+	if got := strings.Join(result.Issuers(), ", "); got != "issue digicert.com, issue letsencrypt.org" {
+		t.Fatalf("unexpected issuers: %s", got)
+	}
+	if len(*queried) != 3 {
+		t.Fatalf("expected to stop climbing at example.com, queried %v", *queried)
+	}
+}
+
+func TestCAAResult_Summary(t *testing.T) {
+	cases := []struct {
+		name   string
+		result CAAResult
+		want   string
+	}{
+		{"no CAA", CAAResult{}, "none"},
+		{"iodef only", CAAResult{Name: "example.com", Records: []CAARecord{{Tag: "iodef", Value: "mailto:security@example.com"}}}, "none (iodef at example.com)"},
+		{"issuers", CAAResult{Name: "example.com", Records: []CAARecord{{Tag: "iodef", Value: "mailto:security@example.com"}, {Tag: "issue", Value: "letsencrypt.org"}}}, "issue letsencrypt.org"},
+	}
+	for _, c := range cases {
+		if got := c.result.Summary(); got != c.want {
+			t.Fatalf("%s: expected %q, got %q", c.name, c.want, got)
+		}
+	}
+}
+
+func TestGetCAARecords_NoneAnywhere(t *testing.T) {
+	resolver := zoneResolver(t)
+	queried := queriedNames(resolver)
+
+	result, err := resolver.GetCAARecords("example.com")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(result.Records) != 0 {
+		t.Fatalf("expected no CAA records, got %v", result.Records)
+	}
+	if strings.Join(*queried, " ") != "example.com. com." {
+		t.Fatalf("expected to climb up to the TLD, queried %v", *queried)
+	}
+}
@@ -0,0 +1,208 @@
+package dnsquery
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// defaultCacheSize is the number of responses DefaultConfig keeps cached.
+const defaultCacheSize = 10000
+
+// maxNegativeTTL caps how long a negative answer is cached, following RFC
+// 2308 section 5.
+const maxNegativeTTL = 3 * time.Hour
+
+// CacheStats reports how often lookups were answered from the cache.
+type CacheStats struct {
+	Hits    uint64
+	Misses  uint64
+	Entries int
+}
+
+// cache keeps upstream replies until their TTL runs out. Once full, the
+// least recently used reply is evicted. A nil *cache caches nothing.
+type cache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	// order holds the entries, most recently used first.
+	order  *list.List
+	hits   uint64
+	misses uint64
+	// now returns the current time; tests override it.
+	now func() time.Time
+}
+
+type cacheEntry struct {
+	key      string
+	response *dns.Msg
+	stored   time.Time
+	expires  time.Time
+}
+
+// newCache returns a cache holding up to size replies, or nil when size is
+// not positive.
+func newCache(size int) *cache {
+	if size <= 0 {
+		return nil
+	}
+	return &cache{
+		size:    size,
+		entries: map[string]*list.Element{},
+		order:   list.New(),
+		now:     time.Now,
+	}
+}
+
+// cacheKey identifies the question of msg along with the flags that change
+// what an upstream returns for it.
+func cacheKey(msg *dns.Msg) string {
+	question := msg.Question[0]
+	key := strings.ToLower(question.Name) + " " + dns.TypeToString[question.Qtype] + " " + dns.ClassToString[question.Qclass]
+	if opt := msg.IsEdns0(); opt != nil && opt.Do() {
+		key += " do"
+	}
+	if msg.CheckingDisabled {
+		key += " cd"
+	}
+	return key
+}
+
+// get returns the cached reply to msg with its TTLs counted down by the time
+// spent in the cache, or nil when there is none.
+func (c *cache) get(msg *dns.Msg) *dns.Msg {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := cacheKey(msg)
+	element, ok := c.entries[key]
+	now := c.now()
+	if ok && !now.Before(element.Value.(*cacheEntry).expires) {
+		c.order.Remove(element)
+		delete(c.entries, key)
+		ok = false
+	}
+	if !ok {
+		c.misses++
+		return nil
+	}
+	c.hits++
+	c.order.MoveToFront(element)
+
+	entry := element.Value.(*cacheEntry)
+	response := entry.response.Copy()
+	response.Id = msg.Id
+	elapsed := uint32(now.Sub(entry.stored) / time.Second)
+	for _, section := range [][]dns.RR{response.Answer, response.Ns, response.Extra} {
+		for _, record := range section {
+			header := record.Header()
+			if header.Rrtype == dns.TypeOPT {
+				continue
+			}
+			if header.Ttl > elapsed {
+				header.Ttl -= elapsed
+			} else {
+				header.Ttl = 0
+			}
+		}
+	}
+	return response
+}
+
+// put stores the reply to msg when it may be cached.
+func (c *cache) put(msg, response *dns.Msg) {
+	if c == nil {
+		return
+	}
+	ttl, ok := cacheTTL(response)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := cacheKey(msg)
+	if element, found := c.entries[key]; found {
+		c.order.Remove(element)
+		delete(c.entries, key)
+	}
+	for c.order.Len() >= c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+	now := c.now()
+	entry := &cacheEntry{key: key, response: response.Copy(), stored: now, expires: now.Add(ttl)}
+	c.entries[key] = c.order.PushFront(entry)
+}
+
+// stats returns the hit and miss counters and the number of cached replies.
+func (c *cache) stats() CacheStats {
+	if c == nil {
+		return CacheStats{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStats{Hits: c.hits, Misses: c.misses, Entries: c.order.Len()}
+}
+
+// cacheTTL returns how long response may be cached. Answers live as long as
+// their lowest TTL. NXDOMAIN and NODATA replies are cached for the lower of
+// the SOA TTL and SOA minimum of the authority section, as RFC 2308 section
+// 5 describes, and not at all without a SOA. Other failures and truncated
+// replies are never cached.
+func cacheTTL(response *dns.Msg) (time.Duration, bool) {
+	if response.Truncated {
+		return 0, false
+	}
+
+	var ttl uint32
+	switch {
+	case response.Rcode == dns.RcodeSuccess && len(response.Answer) > 0:
+		ttl = response.Answer[0].Header().Ttl
+		for _, record := range response.Answer[1:] {
+			if record.Header().Ttl < ttl {
+				ttl = record.Header().Ttl
+			}
+		}
+	case response.Rcode == dns.RcodeSuccess || response.Rcode == dns.RcodeNameError:
+		var soa *dns.SOA
+		for _, record := range response.Ns {
+			if record, ok := record.(*dns.SOA); ok {
+				soa = record
+				break
+			}
+		}
+		if soa == nil {
+			return 0, false
+		}
+		ttl = soa.Hdr.Ttl
+		if soa.Minttl < ttl {
+			ttl = soa.Minttl
+		}
+		if negative := time.Duration(ttl) * time.Second; negative > maxNegativeTTL {
+			return maxNegativeTTL, true
+		}
+	default:
+		return 0, false
+	}
+
+	if ttl == 0 {
+		return 0, false
+	}
+	return time.Duration(ttl) * time.Second, true
+}
+
+// CacheStats returns how many lookups were answered from the cache and how
+// many had to be sent upstream. Every copy made by WithContext shares the
+// cache and its counters.
+func (r *Resolver) CacheStats() CacheStats {
+	return r.cache.stats()
+}
@@ -0,0 +1,155 @@
+package dnsquery
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// cachingResolver returns a Resolver with a cache of size replies whose
+// clock is *now. Queries for nx.example.com. get NXDOMAIN with a SOA, every
+// other name one A record with ttl. The number of upstream exchanges is
+// counted in *exchanges.
+func cachingResolver(size int, ttl uint32, now *time.Time, exchanges *int) *Resolver {
+	resolver := NewResolver(Config{
+		Servers:   []string{"192.0.2.1"},
+		CacheSize: size,
+		Exchanger: exchangeFunc(func(msg *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+			*exchanges++
+			question := msg.Question[0]
+			if question.Name == "nx.example.com." {
+				reply := rcode(msg, dns.RcodeNameError)
+				reply.Ns = []dns.RR{&dns.SOA{
+					Hdr: dns.RR_Header{Name: "example.com.", Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: 3600},
+					Ns:  "ns1.example.com.", Mbox: "hostmaster.example.com.", Minttl: 60,
+				}}
+				return reply, 0, nil
+			}
+			return answer(msg, &dns.A{Hdr: dns.RR_Header{Name: question.Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: ttl}, A: []byte{192, 0, 2, 10}}), 0, nil
+		}),
+	})
+	resolver.cache.now = func() time.Time { return *now }
+	return resolver
+}
+
+func TestCache_AnswersRepeatedQueries(t *testing.T) {
+	now, exchanges := time.Unix(1700000000, 0), 0
+	resolver := cachingResolver(10, 300, &now, &exchanges)
+
+	if _, err := resolver.Query("www.example.com", dns.TypeA); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	now = now.Add(100 * time.Second)
+	records, err := resolver.Query("WWW.example.com", dns.TypeA)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if exchanges != 1 {
+		t.Fatalf("expected the second query to be cached, got %d exchanges", exchanges)
+	}
+	if ttl := records[0].Header().Ttl; ttl != 200 {
+		t.Fatalf("expected the TTL to count down to 200, got %d", ttl)
+	}
+	if stats := resolver.CacheStats(); stats.Hits != 1 || stats.Misses != 1 || stats.Entries != 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestCache_ExpiresWithTTL(t *testing.T) {
+	now, exchanges := time.Unix(1700000000, 0), 0
+	resolver := cachingResolver(10, 300, &now, &exchanges)
+
+	resolver.Query("www.example.com", dns.TypeA)
+	now = now.Add(300 * time.Second)
+	resolver.Query("www.example.com", dns.TypeA)
+	if exchanges != 2 {
+		t.Fatalf("expected the expired reply to be fetched again, got %d exchanges", exchanges)
+	}
+}
+
+func TestCache_CachesNXDOMAINForSOAMinimum(t *testing.T) {
+	now, exchanges := time.Unix(1700000000, 0), 0
+	resolver := cachingResolver(10, 300, &now, &exchanges)
+
+	for _, elapsed := range []time.Duration{0, 59 * time.Second, time.Second} {
+		now = now.Add(elapsed)
+		if _, err := resolver.Query("nx.example.com", dns.TypeA); !errors.Is(err, ErrNXDomain) {
+			t.Fatalf("expected ErrNXDomain, got %v", err)
+		}
+	}
+	if exchanges != 2 {
+		t.Fatalf("expected NXDOMAIN to be cached for the SOA minimum of 60s, got %d exchanges", exchanges)
+	}
+}
+
+func TestCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	now, exchanges := time.Unix(1700000000, 0), 0
+	resolver := cachingResolver(2, 300, &now, &exchanges)
+
+	resolver.Query("a.example.com", dns.TypeA)
+	resolver.Query("b.example.com", dns.TypeA)
+	resolver.Query("a.example.com", dns.TypeA)
+	resolver.Query("c.example.com", dns.TypeA)
+	if exchanges != 3 {
+		t.Fatalf("expected 3 exchanges, got %d", exchanges)
+	}
+
+	resolver.Query("a.example.com", dns.TypeA)
+	if exchanges != 3 {
+		t.Fatalf("expected a.example.com to stay cached, got %d exchanges", exchanges)
+	}
+	resolver.Query("b.example.com", dns.TypeA)
+	if exchanges != 4 {
+		t.Fatalf("expected b.example.com to be evicted, got %d exchanges", exchanges)
+	}
+	if stats := resolver.CacheStats(); stats.Entries != 2 {
+		t.Fatalf("expected the cache to hold 2 replies, got %d", stats.Entries)
+	}
+}
+
+func TestCache_DoesNotCacheFailures(t *testing.T) {
+	exchanges := 0
+	resolver := NewResolver(Config{
+		Servers:   []string{"192.0.2.1"},
+		CacheSize: 10,
+		Exchanger: exchangeFunc(func(msg *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+			exchanges++
+			return rcode(msg, dns.RcodeServerFailure), 0, nil
+		}),
+	})
+
+	resolver.Query("example.com", dns.TypeA)
+	resolver.Query("example.com", dns.TypeA)
+	if exchanges != 2 {
+		t.Fatalf("expected SERVFAIL not to be cached, got %d exchanges", exchanges)
+	}
+}
+
+func TestCache_DisabledWithZeroSize(t *testing.T) {
+	now, exchanges := time.Unix(1700000000, 0), 0
+	resolver := cachingResolver(1, 300, &now, &exchanges)
+	resolver.cache = newCache(0)
+
+	resolver.Query("www.example.com", dns.TypeA)
+	resolver.Query("www.example.com", dns.TypeA)
+	if exchanges != 2 {
+		t.Fatalf("expected every query to be sent, got %d exchanges", exchanges)
+	}
+	if stats := resolver.CacheStats(); stats != (CacheStats{}) {
+		t.Fatalf("expected empty stats, got %+v", stats)
+	}
+}
+
+func TestCacheKey_SeparatesDNSSECQueries(t *testing.T) {
+	plain := new(dns.Msg)
+	plain.SetQuestion("example.com.", dns.TypeSOA)
+	plain.SetEdns0(1232, false)
+	signed := plain.Copy()
+	signed.IsEdns0().SetDo()
+
+	if cacheKey(plain) == cacheKey(signed) {
+		t.Fatalf("expected queries with the DO bit to be cached separately")
+	}
+}
@@ -0,0 +1,143 @@
+package dnsquery
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// cnameMaxDepth bounds how many CNAMEs are followed before a chain is
+// considered broken.
+const cnameMaxDepth = 16
+
+// takeoverProneSuffixes lists hosting providers where a CNAME left behind
+// after the resource was deleted can be claimed by anyone creating a
+// resource of the same name.
+var takeoverProneSuffixes = []string{
+	"azurewebsites.net",
+	"blob.core.windows.net",
+	"cloudapp.net",
+	"cloudapp.azure.com",
+	"trafficmanager.net",
+	"azureedge.net",
+	"s3.amazonaws.com",
+	"elasticbeanstalk.com",
+	"cloudfront.net",
+	"herokuapp.com",
+	"herokudns.com",
+	"github.io",
+	"bitbucket.io",
+	"netlify.app",
+	"surge.sh",
+	"ghost.io",
+	"pantheonsite.io",
+	"myshopify.com",
+	"zendesk.com",
+	"readme.io",
+	"helpscoutdocs.com",
+	"unbouncepages.com",
+	"wordpress.com",
+	"fly.dev",
+}
+
+// CNAMEChain is the result of following the CNAMEs of a name.
+type CNAMEChain struct {
+	Name string
+	// Targets lists the CNAME targets in the order they were followed.
+	Targets []string
+	// Dangling is set when the chain ends in a name that does not exist,
+	// so whoever registers it controls Name.
+	Dangling bool
+	// Provider is the takeover-prone provider suffix the chain points
+	// into, if any.
+	Provider string
+	Issues   []string
+}
+
+func (c *CNAMEChain) addIssue(format string, args ...interface{}) {
+	c.Issues = append(c.Issues, fmt.Sprintf(format, args...))
+}
+
+// String renders the chain as "a.example.com. -> b.example.net.".
+func (c *CNAMEChain) String() string {
+	return strings.Join(append([]string{c.Name}, c.Targets...), " -> ")
+}
+
+// takeoverProvider returns the takeover-prone suffix name falls under.
+func takeoverProvider(name string) string {
+	name = strings.TrimSuffix(strings.ToLower(name), ".")
+	for _, suffix := range takeoverProneSuffixes {
+		if dns.IsSubDomain(suffix, name) {
+			return suffix
+		}
+	}
+	return ""
+}
+
+// ResolveCNAMEChain follows the CNAMEs of name using the default resolver.
+func ResolveCNAMEChain(name string) (*CNAMEChain, error) {
+	return defaultResolver.ResolveCNAMEChain(name)
+}
+
+// ResolveCNAMEChain follows the CNAMEs of name one hop at a time and
+// reports chains that end in NXDOMAIN, loop or point into a takeover-prone
+// provider. A name that does not exist itself is not an error and yields an
+// empty chain; errors are only returned for failed lookups.
+func (r *Resolver) ResolveCNAMEChain(name string) (*CNAMEChain, error) {
+	name, err := ToASCII(name)
+	if err != nil {
+		return nil, err
+	}
+	chain := &CNAMEChain{Name: dns.Fqdn(strings.ToLower(name))}
+	seen := map[string]bool{chain.Name: true}
+
+	current := chain.Name
+	for {
+		records, err := r.Query(current, dns.TypeCNAME)
+		if errors.Is(err, ErrNXDomain) {
+			if current != chain.Name {
+				chain.Dangling = true
+				chain.addIssue("CNAME target %s does not exist (NXDOMAIN)", current)
+			}
+			break
+		}
+		if errors.Is(err, ErrNoData) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		target := ""
+		for _, record := range records {
+			if cname, ok := record.(*dns.CNAME); ok && strings.EqualFold(cname.Hdr.Name, current) {
+				target = strings.ToLower(cname.Target)
+			}
+		}
+		if target == "" {
+			break
+		}
+		chain.Targets = append(chain.Targets, target)
+		if seen[target] {
+			chain.addIssue("CNAME loop at %s", target)
+			break
+		}
+		if len(chain.Targets) > cnameMaxDepth {
+			chain.addIssue("CNAME chain longer than %d hops", cnameMaxDepth)
+			break
+		}
+		seen[target] = true
+		current = target
+	}
+
+	for _, target := range chain.Targets {
+		if provider := takeoverProvider(target); provider != "" {
+			chain.Provider = provider
+			chain.addIssue("CNAME points at takeover-prone provider %s via %s", provider, target)
+			break
+		}
+	}
+	return chain, nil
+}
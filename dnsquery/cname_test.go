@@ -0,0 +1,69 @@
+package dnsquery
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResolveCNAMEChain_FollowsChain(t *testing.T) {
+	resolver := zoneResolver(t,
+		`shop.example.com. 300 IN CNAME edge.example.net.`,
+		`edge.example.net. 300 IN CNAME lb.example.org.`,
+		`lb.example.org. 300 IN A 192.0.2.10`,
+	)
+
+	chain, err := resolver.ResolveCNAMEChain("shop.example.com")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if chain.String() != "shop.example.com. -> edge.example.net. -> lb.example.org." {
+		t.Fatalf("unexpected chain: %s", chain)
+	}
+	if chain.Dangling || len(chain.Issues) != 0 {
+		t.Fatalf("unexpected issues: %v", chain.Issues)
+	}
+}
+
+func TestResolveCNAMEChain_Dangling(t *testing.T) {
+	resolver := zoneResolver(t, `docs.example.com. 300 IN CNAME example-docs.herokuapp.com.`)
+
+	chain, err := resolver.ResolveCNAMEChain("docs.example.com")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if !chain.Dangling || chain.Provider != "herokuapp.com" {
+		t.Fatalf("expected dangling heroku chain, got %+v", chain)
+	}
+	if len(chain.Issues) != 2 || !strings.Contains(chain.Issues[0], "NXDOMAIN") {
+		t.Fatalf("unexpected issues: %v", chain.Issues)
+	}
+}
+
+func TestResolveCNAMEChain_Loop(t *testing.T) {
+	resolver := zoneResolver(t,
+		`a.example.com. 300 IN CNAME b.example.com.`,
+		`b.example.com. 300 IN CNAME a.example.com.`,
+	)
+
+	chain, err := resolver.ResolveCNAMEChain("a.example.com")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(chain.Issues) != 1 || !strings.Contains(chain.Issues[0], "loop") {
+		t.Fatalf("expected loop issue, got %v", chain.Issues)
+	}
+}
+
+func TestResolveCNAMEChain_NoCNAME(t *testing.T) {
+	resolver := zoneResolver(t, `www.example.com. 300 IN A 192.0.2.10`)
+
+	for _, name := range []string{"www.example.com", "missing.example.com"} {
+		chain, err := resolver.ResolveCNAMEChain(name)
+		if err != nil {
+			t.Fatalf("%s: unexpected err: %v", name, err)
+		}
+		if len(chain.Targets) != 0 || chain.Dangling {
+			t.Fatalf("%s: expected empty chain, got %+v", name, chain)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package dnsquery
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+// Disagreement records a query that the servers answered differently, as
+// seen during partial propagation or when a resolver is poisoned.
+type Disagreement struct {
+	Name  string
+	Qtype uint16
+	// Answers maps every distinct answer to the servers that gave it.
+	Answers map[string][]string
+	// Consensus is the answer that reached the quorum, if any.
+	Consensus string
+}
+
+func (d Disagreement) String() string {
+	keys := make([]string, 0, len(d.Answers))
+	for answer := range d.Answers {
+		keys = append(keys, answer)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, answer := range keys {
+		parts = append(parts, fmt.Sprintf("%s answered %q", strings.Join(d.Answers[answer], ", "), answer))
+	}
+	summary := "no consensus"
+	if d.Consensus != "" {
+		summary = fmt.Sprintf("consensus %q", d.Consensus)
+	}
+	return fmt.Sprintf("%s %s: %s; %s", dns.TypeToString[d.Qtype], d.Name, summary, strings.Join(parts, "; "))
+}
+
+// Disagreements returns the disagreements seen since the previous call and
+// forgets them.
+func (r *Resolver) Disagreements() []Disagreement {
+	r.state.mu.Lock()
+	defer r.state.mu.Unlock()
+	disagreements := r.state.disagreements
+	r.state.disagreements = nil
+	return disagreements
+}
+
+// answerKey renders the parts of a reply the servers have to agree on: the
+// rcode and the answer records, ignoring order and TTLs since caches count
+// those down independently.
+func answerKey(response *dns.Msg) string {
+	records := make([]string, 0, len(response.Answer))
+	for _, record := range response.Answer {
+		record = dns.Copy(record)
+		record.Header().Ttl = 0
+		records = append(records, strings.ReplaceAll(record.String(), "\t", " "))
+	}
+	sort.Strings(records)
+	return strings.Join(append([]string{dns.RcodeToString[response.Rcode]}, records...), " | ")
+}
+
+// exchangeConsensus sends msg to every server in parallel and returns the
+// reply a quorum of them agreed on. Servers that fail, SERVFAIL or REFUSE
+// do not vote. With a quorum of half the servers or less two answers can
+// both reach it; the one with the most votes wins, and a tie is no
+// consensus. Differing answers are recorded as a Disagreement even when a
+// quorum was reached.
+func (r *Resolver) exchangeConsensus(msg *dns.Msg) (*dns.Msg, error) {
+	servers := r.Servers()
+	responses := make([]*dns.Msg, len(servers))
+	errs := make([]error, len(servers))
+
+	var wg sync.WaitGroup
+	for i, server := range servers {
+		wg.Add(1)
+		go func(i int, server string) {
+			defer wg.Done()
+			for attempt := 0; attempt <= r.retries; attempt++ {
+				if errs[i] = r.ctx.Err(); errs[i] != nil {
+					return
+				}
+				responses[i], errs[i] = r.exchangeServer(r.exchanger, msg.Copy(), server)
+				if errs[i] == nil && responses[i].Rcode != dns.RcodeServerFailure && responses[i].Rcode != dns.RcodeRefused {
+					return
+				}
+			}
+		}(i, server)
+	}
+	wg.Wait()
+
+	var (
+		answers = map[string][]string{}
+		replies = map[string]*dns.Msg{}
+		lastErr error
+		last    *dns.Msg
+	)
+	for i, server := range servers {
+		if errs[i] != nil {
+			lastErr = errs[i]
+			continue
+		}
+		last = responses[i]
+		if responses[i].Rcode == dns.RcodeServerFailure || responses[i].Rcode == dns.RcodeRefused {
+			continue
+		}
+		key := answerKey(responses[i])
+		answers[key] = append(answers[key], server)
+		if replies[key] == nil {
+			replies[key] = responses[i]
+		}
+	}
+
+	consensus, votes := "", 0
+	for key, voters := range answers {
+		switch {
+		case len(voters) > votes:
+			consensus, votes = key, len(voters)
+		case len(voters) == votes:
+			consensus = ""
+		}
+	}
+	if votes < r.quorum {
+		consensus = ""
+	}
+	if len(answers) > 1 {
+		question := msg.Question[0]
+		r.state.mu.Lock()
+		r.state.disagreements = append(r.state.disagreements, Disagreement{Name: question.Name, Qtype: question.Qtype, Answers: answers, Consensus: consensus})
+		r.state.mu.Unlock()
+	}
+
+	switch {
+	case consensus != "":
+		return replies[consensus], nil
+	case len(answers) > 0:
+		return nil, fmt.Errorf("no answer from a quorum of %d servers: %w", r.quorum, ErrNoConsensus)
+	case last != nil:
+		return last, nil
+	}
+	return nil, lastErr
+}
@@ -0,0 +1,111 @@
+package dnsquery
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+// votingResolver returns a consensus Resolver whose servers answer the A
+// query of example.com with the address listed for them, or time out when
+// the address is empty.
+func votingResolver(t *testing.T, quorum int, addresses map[string]string) *Resolver {
+	t.Helper()
+	var servers []string
+	zones := map[string][]string{}
+	for server, address := range addresses {
+		servers = append(servers, server)
+		if address != "" {
+			zones[server] = []string{"example.com. 300 IN A " + address}
+		}
+	}
+	return zonesResolver(t, Config{Servers: servers, Strategy: StrategyConsensus, Quorum: quorum}, zones)
+}
+
+func TestConsensus_QuorumAgrees(t *testing.T) {
+	resolver := votingResolver(t, 0, map[string]string{
+		"192.0.2.1": "198.51.100.1",
+		"192.0.2.2": "198.51.100.1",
+		"192.0.2.3": "203.0.113.66",
+	})
+
+	records, err := resolver.Query("example.com", dns.TypeA)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(records) != 1 || records[0].(*dns.A).A.String() != "198.51.100.1" {
+		t.Fatalf("expected the majority answer, got %v", records)
+	}
+
+	disagreements := resolver.Disagreements()
+	if len(disagreements) != 1 || disagreements[0].Qtype != dns.TypeA || disagreements[0].Consensus == "" {
+		t.Fatalf("expected one A disagreement with consensus, got %+v", disagreements)
+	}
+	if answers := disagreements[0].Answers; len(answers) != 2 {
+		t.Fatalf("expected two distinct answers, got %v", answers)
+	}
+	if len(resolver.Disagreements()) != 0 {
+		t.Fatal("expected disagreements to be cleared")
+	}
+}
+
+func TestConsensus_NoQuorum(t *testing.T) {
+	resolver := votingResolver(t, 0, map[string]string{
+		"192.0.2.1": "198.51.100.1",
+		"192.0.2.2": "203.0.113.66",
+		"192.0.2.3": "",
+	})
+
+	_, err := resolver.Query("example.com", dns.TypeA)
+	if !errors.Is(err, ErrNoConsensus) || IsNotFound(err) {
+		t.Fatalf("expected ErrNoConsensus, got %v", err)
+	}
+	var lookupErr *LookupError
+	if !errors.As(err, &lookupErr) || lookupErr.Kind != KindNoConsensus {
+		t.Fatalf("expected KindNoConsensus, got %v", err)
+	}
+	if disagreements := resolver.Disagreements(); len(disagreements) != 1 || disagreements[0].Consensus != "" {
+		t.Fatalf("expected a disagreement without consensus, got %+v", disagreements)
+	}
+}
+
+func TestConsensus_AllAgree(t *testing.T) {
+	resolver := votingResolver(t, 3, map[string]string{
+		"192.0.2.1": "198.51.100.1",
+		"192.0.2.2": "198.51.100.1",
+		"192.0.2.3": "198.51.100.1",
+	})
+
+	if _, err := resolver.Query("example.com", dns.TypeA); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if disagreements := resolver.Disagreements(); len(disagreements) != 0 {
+		t.Fatalf("expected no disagreements, got %+v", disagreements)
+	}
+}
+
+func TestConsensus_LowQuorum(t *testing.T) {
+	// With a quorum of 1 every answer reaches it: the most voted wins and a
+	// tie is no consensus, whatever order the answers are looked at
+	for i := 0; i < 20; i++ {
+		resolver := votingResolver(t, 1, map[string]string{
+			"192.0.2.1": "198.51.100.1",
+			"192.0.2.2": "198.51.100.1",
+			"192.0.2.3": "203.0.113.66",
+			"192.0.2.4": "203.0.113.77",
+		})
+		records, err := resolver.Query("example.com", dns.TypeA)
+		if err != nil || len(records) != 1 || records[0].(*dns.A).A.String() != "198.51.100.1" {
+			t.Fatalf("expected the most voted answer, got %v, %v", records, err)
+		}
+
+		resolver = votingResolver(t, 1, map[string]string{
+			"192.0.2.1": "198.51.100.1",
+			"192.0.2.2": "203.0.113.66",
+		})
+		if _, err := resolver.Query("example.com", dns.TypeA); !errors.Is(err, ErrNoConsensus) {
+			t.Fatalf("expected ErrNoConsensus on a tie, got %v", err)
+		}
+	}
+}
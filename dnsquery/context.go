@@ -0,0 +1,91 @@
+package dnsquery
+
+import (
+	"context"
+
+	"github.com/miekg/dns"
+)
+
+// The functions below are the context-aware variants of the record getters.
+// They stop waiting for upstream servers when ctx is done; any other lookup
+// can be bound to a context with Resolver.WithContext.
+
+// DNSQueryContext is DNSQuery bound to ctx.
+func DNSQueryContext(ctx context.Context, domain string, qtype uint16) ([]dns.RR, error) {
+	return defaultResolver.QueryContext(ctx, domain, qtype)
+}
+
+// QueryContext is Query bound to ctx.
+func (r *Resolver) QueryContext(ctx context.Context, domain string, qtype uint16) ([]dns.RR, error) {
+	return r.WithContext(ctx).Query(domain, qtype)
+}
+
+// GetTXTRecordsContext is GetTXTRecords bound to ctx.
+func GetTXTRecordsContext(ctx context.Context, domain string) ([]string, error) {
+	return defaultResolver.GetTXTRecordsContext(ctx, domain)
+}
+
+// GetTXTRecordsContext is GetTXTRecords bound to ctx.
+func (r *Resolver) GetTXTRecordsContext(ctx context.Context, domain string) ([]string, error) {
+	return r.WithContext(ctx).GetTXTRecords(domain)
+}
+
+// GetNSRecordsContext is GetNSRecords bound to ctx.
+func GetNSRecordsContext(ctx context.Context, domain string) ([]string, error) {
+	return defaultResolver.GetNSRecordsContext(ctx, domain)
+}
+
+// GetNSRecordsContext is GetNSRecords bound to ctx.
+func (r *Resolver) GetNSRecordsContext(ctx context.Context, domain string) ([]string, error) {
+	return r.WithContext(ctx).GetNSRecords(domain)
+}
+
+// GetMXRecordsContext is GetMXRecords bound to ctx.
+func GetMXRecordsContext(ctx context.Context, domain string) ([]MXRecord, error) {
+	return defaultResolver.GetMXRecordsContext(ctx, domain)
+}
+
+// GetMXRecordsContext is GetMXRecords bound to ctx.
+func (r *Resolver) GetMXRecordsContext(ctx context.Context, domain string) ([]MXRecord, error) {
+	return r.WithContext(ctx).GetMXRecords(domain)
+}
+
+// GetAddressesContext is GetAddresses bound to ctx.
+func GetAddressesContext(ctx context.Context, name string) ([]string, error) {
+	return defaultResolver.GetAddressesContext(ctx, name)
+}
+
+// GetAddressesContext is GetAddresses bound to ctx.
+func (r *Resolver) GetAddressesContext(ctx context.Context, name string) ([]string, error) {
+	return r.WithContext(ctx).GetAddresses(name)
+}
+
+// GetDMARCRecordContext is GetDMARCRecord bound to ctx.
+func GetDMARCRecordContext(ctx context.Context, domain string) (string, error) {
+	return defaultResolver.GetDMARCRecordContext(ctx, domain)
+}
+
+// GetDMARCRecordContext is GetDMARCRecord bound to ctx.
+func (r *Resolver) GetDMARCRecordContext(ctx context.Context, domain string) (string, error) {
+	return r.WithContext(ctx).GetDMARCRecord(domain)
+}
+
+// GetSPFRecordContext is GetSPFRecord bound to ctx.
+func GetSPFRecordContext(ctx context.Context, domain string) (string, error) {
+	return defaultResolver.GetSPFRecordContext(ctx, domain)
+}
+
+// GetSPFRecordContext is GetSPFRecord bound to ctx.
+func (r *Resolver) GetSPFRecordContext(ctx context.Context, domain string) (string, error) {
+	return r.WithContext(ctx).GetSPFRecord(domain)
+}
+
+// GetDKIMRecordContext is GetDKIMRecord bound to ctx.
+func GetDKIMRecordContext(ctx context.Context, domain, selector string) (string, error) {
+	return defaultResolver.GetDKIMRecordContext(ctx, domain, selector)
+}
+
+// GetDKIMRecordContext is GetDKIMRecord bound to ctx.
+func (r *Resolver) GetDKIMRecordContext(ctx context.Context, domain, selector string) (string, error) {
+	return r.WithContext(ctx).GetDKIMRecord(domain, selector)
+}
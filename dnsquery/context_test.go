@@ -0,0 +1,112 @@
+package dnsquery
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// blockingExchanger never answers and returns once ctx is done, like an
+// upstream that stopped responding.
+type blockingExchanger struct {
+	calls int
+}
+
+func (b *blockingExchanger) ExchangeContext(ctx context.Context, msg *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+	b.calls++
+	<-ctx.Done()
+	return nil, 0, ctx.Err()
+}
+
+func TestQueryContext_CancelledContextSkipsServers(t *testing.T) {
+	exchanger := &blockingExchanger{}
+	resolver := NewResolver(Config{Servers: []string{"10.0.0.1", "10.0.0.2"}, Retries: 1, Exchanger: exchanger})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := resolver.QueryContext(ctx, "example.com", dns.TypeNS)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if exchanger.calls != 0 {
+		t.Fatalf("expected no exchange after cancellation, got %d", exchanger.calls)
+	}
+}
+
+func TestQueryContext_DeadlineIsTimeout(t *testing.T) {
+	exchanger := &blockingExchanger{}
+	resolver := NewResolver(Config{Servers: []string{"10.0.0.1", "10.0.0.2"}, Exchanger: exchanger})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := resolver.GetTXTRecordsContext(ctx, "example.com")
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected ErrTimeout, got %v", err)
+	}
+	if exchanger.calls != 1 {
+		t.Fatalf("expected the second server to be skipped, got %d exchanges", exchanger.calls)
+	}
+}
+
+func TestWithContext_SharesRecordedState(t *testing.T) {
+	resolver := NewResolver(Config{
+		Servers: []string{"10.0.0.1"},
+		Exchanger: exchangeFunc(func(msg *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+			response := answer(msg)
+			response.Truncated = true
+			return response, 0, nil
+		}),
+		TCPExchanger: exchangeFunc(func(msg *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+			return answer(msg, nsRecord(msg)), 0, nil
+		}),
+	})
+
+	if _, err := resolver.WithContext(context.Background()).Query("example.com", dns.TypeNS); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if fallbacks := resolver.TCPFallbacks(); len(fallbacks) != 1 {
+		t.Fatalf("expected the fallback to be recorded on the parent resolver, got %v", fallbacks)
+	}
+}
+
+func TestContextDialer_ClosesConnectionWhenDone(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		// Accept and hold the connection open without replying
+		conn, err := listener.Accept()
+		if err == nil {
+			defer conn.Close()
+			time.Sleep(time.Second)
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	conn, err := (&contextDialer{ctx: ctx}).Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	cancel()
+	done := make(chan error, 1)
+	go func() {
+		_, err := conn.Read(make([]byte, 1))
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatalf("expected read to fail after cancellation")
+		}
+	case <-time.After(500 * time.Millisecond):
+		t.Fatalf("read still blocked after cancellation")
+	}
+}
@@ -0,0 +1,95 @@
+package dnsquery
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// DKIMKey is the parsed content of a DKIM key record (RFC 6376 section 3.6.1).
+type DKIMKey struct {
+	KeyType string
+	// Bits is the key length: the modulus size for RSA, 256 for Ed25519.
+	Bits int
+	// Revoked is set when the record publishes an empty p= tag.
+	Revoked bool
+	// PublicKey is the base64 p= value with whitespace removed.
+	PublicKey string
+}
+
+func (k DKIMKey) String() string {
+	if k.Revoked {
+		return k.KeyType + " revoked"
+	}
+	return fmt.Sprintf("%s %d", k.KeyType, k.Bits)
+}
+
+// ParseDKIMRecord parses a DKIM key record and decodes its public key to
+// find the key type and length.
+func ParseDKIMRecord(record string) (*DKIMKey, error) {
+	tags := parseTags(record)
+
+	if v, ok := tags["v"]; ok && v != "DKIM1" {
+		return nil, fmt.Errorf("unsupported DKIM version %q", v)
+	}
+	p, ok := tags["p"]
+	if !ok {
+		return nil, fmt.Errorf("DKIM record has no p= tag")
+	}
+
+	key := &DKIMKey{KeyType: strings.ToLower(tags["k"]), PublicKey: strings.Join(strings.Fields(p), "")}
+	if key.KeyType == "" {
+		key.KeyType = "rsa"
+	}
+	if key.PublicKey == "" {
+		key.Revoked = true
+		return key, nil
+	}
+
+	der, err := base64.StdEncoding.DecodeString(key.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DKIM public key: %w", err)
+	}
+
+	switch key.KeyType {
+	case "rsa":
+		publicKey, err := x509.ParsePKIXPublicKey(der)
+		if err != nil {
+			// Some signers publish a bare PKCS#1 key instead of SubjectPublicKeyInfo.
+			publicKey, err = x509.ParsePKCS1PublicKey(der)
+			if err != nil {
+				return nil, fmt.Errorf("invalid DKIM RSA key: %w", err)
+			}
+		}
+		rsaKey, ok := publicKey.(*rsa.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("DKIM key type rsa carries a %T", publicKey)
+		}
+		key.Bits = rsaKey.N.BitLen()
+	case "ed25519":
+		if len(der) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid DKIM Ed25519 key length %d", len(der))
+		}
+		key.Bits = 8 * ed25519.PublicKeySize
+	default:
+		return nil, fmt.Errorf("unsupported DKIM key type %q", key.KeyType)
+	}
+	return key, nil
+}
+
+// parseTags splits a "tag=value; tag=value" list as used by DKIM and DMARC
+// records. Tag names are lower-cased and values trimmed.
+func parseTags(record string) map[string]string {
+	tags := map[string]string{}
+	for _, part := range strings.Split(record, ";") {
+		name, value, found := strings.Cut(part, "=")
+		if !found {
+			continue
+		}
+		tags[strings.ToLower(strings.TrimSpace(name))] = strings.TrimSpace(value)
+	}
+	return tags
+}
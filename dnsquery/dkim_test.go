@@ -0,0 +1,67 @@
+package dnsquery
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"testing"
+)
+
+func TestParseDKIMRecord_RSA(t *testing.T) {
+	private, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+
+	pkix, err := x509.MarshalPKIXPublicKey(&private.PublicKey)
+	if err != nil {
+		t.Fatalf("marshalling key: %v", err)
+	}
+	pkcs1 := x509.MarshalPKCS1PublicKey(&private.PublicKey)
+
+	for name, der := range map[string][]byte{"pkix": pkix, "pkcs1": pkcs1} {
+		key, err := ParseDKIMRecord("v=DKIM1; k=rsa; p=" + base64.StdEncoding.EncodeToString(der))
+		if err != nil {
+			t.Fatalf("%s: unexpected err: %v", name, err)
+		}
+		if key.KeyType != "rsa" || key.Bits != 1024 {
+			t.Fatalf("%s: unexpected key: %s", name, key)
+		}
+	}
+}
+
+func TestParseDKIMRecord_Ed25519(t *testing.T) {
+	public, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+
+	key, err := ParseDKIMRecord("v=DKIM1; k=ed25519; p=" + base64.StdEncoding.EncodeToString(public))
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if key.String() != "ed25519 256" {
+		t.Fatalf("unexpected key: %s", key)
+	}
+}
+
+func TestParseDKIMRecord_Revoked(t *testing.T) {
+	key, err := ParseDKIMRecord("v=DKIM1; p=")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if !key.Revoked || key.KeyType != "rsa" {
+		t.Fatalf("expected revoked rsa key, got %+v", key)
+	}
+}
+
+func TestParseDKIMRecord_Invalid(t *testing.T) {
+	if _, err := ParseDKIMRecord("v=DKIM1; k=rsa; p=not-base64!"); err == nil {
+		t.Fatalf("expected error for invalid key")
+	}
+	if _, err := ParseDKIMRecord("v=DKIM1; k=rsa"); err == nil {
+		t.Fatalf("expected error without p= tag")
+	}
+}
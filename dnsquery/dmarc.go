@@ -0,0 +1,370 @@
+package dnsquery
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// DMARCStrength classifies how strictly a DMARC record protects a domain.
+// Higher values are stronger.
+type DMARCStrength int
+
+const (
+	// DMARCStrengthMissing means no usable DMARC record is published.
+	DMARCStrengthMissing DMARCStrength = iota
+	// DMARCStrengthNone means p=none: reports only, nothing is enforced.
+	DMARCStrengthNone
+	// DMARCStrengthPartial means an enforcing policy applied to less than
+	// 100 percent of the failing mail.
+	DMARCStrengthPartial
+	// DMARCStrengthQuarantine means failing mail is quarantined.
+	DMARCStrengthQuarantine
+	// DMARCStrengthReject means failing mail is rejected.
+	DMARCStrengthReject
+)
+
+func (s DMARCStrength) String() string {
+	switch s {
+	case DMARCStrengthNone:
+		return "none"
+	case DMARCStrengthPartial:
+		return "partial"
+	case DMARCStrengthQuarantine:
+		return "quarantine"
+	case DMARCStrengthReject:
+		return "reject"
+	}
+	return "missing"
+}
+
+// DMARCRecord is a parsed DMARC record (RFC 7489 section 6.3). Tags left out
+// of the record hold their RFC defaults.
+type DMARCRecord struct {
+	Raw             string
+	Policy          string
+	SubdomainPolicy string
+	Percent         int
+	RUA             []string
+	RUF             []string
+	ADKIM           string
+	ASPF            string
+	FailureOptions  string
+	// Destinations lists the rua and ruf URIs on other domains, filled in
+	// by AnalyzeDMARC.
+	Destinations []ReportDestination
+	// Issues lists syntax errors, unknown and duplicate tags and, after
+	// AnalyzeDMARC, unauthorized report destinations.
+	Issues []string
+}
+
+// ReportDestination is a rua or ruf URI pointing at another domain, which
+// has to authorize receiving reports for us (RFC 7489 section 7.1).
+type ReportDestination struct {
+	URI    string
+	Domain string
+	// Authorized is set when the destination publishes the
+	// <our-domain>._report._dmarc.<their-domain> record.
+	Authorized bool
+}
+
+// Strength classifies the policy applied to the domain itself.
+func (d *DMARCRecord) Strength() DMARCStrength {
+	switch d.Policy {
+	case "reject", "quarantine":
+		if d.Percent < 100 {
+			return DMARCStrengthPartial
+		}
+		if d.Policy == "reject" {
+			return DMARCStrengthReject
+		}
+		return DMARCStrengthQuarantine
+	case "none":
+		return DMARCStrengthNone
+	}
+	return DMARCStrengthMissing
+}
+
+// PolicyDescription describes the policy for notifications, such as
+// "reject" or "quarantine at 50%".
+func (d *DMARCRecord) PolicyDescription() string {
+	if d.Policy == "" {
+		return "missing"
+	}
+	if d.Percent < 100 && d.Policy != "none" {
+		return fmt.Sprintf("%s at %d%%", d.Policy, d.Percent)
+	}
+	return d.Policy
+}
+
+func (d *DMARCRecord) addIssue(format string, args ...interface{}) {
+	d.Issues = append(d.Issues, fmt.Sprintf(format, args...))
+}
+
+// isDMARCRecord reports whether a TXT string starts with the DMARC version tag.
+func isDMARCRecord(txt string) bool {
+	return strings.HasPrefix(txt, "v=DMARC1")
+}
+
+// ParseDMARC parses a DMARC record. Problems inside the record are reported
+// in Issues; an error is only returned when the string is not a DMARC record.
+func ParseDMARC(record string) (*DMARCRecord, error) {
+	if !isDMARCRecord(record) {
+		return nil, fmt.Errorf("not a DMARC record: %q", record)
+	}
+
+	dmarc := &DMARCRecord{Raw: record, Percent: 100, ADKIM: "r", ASPF: "r", FailureOptions: "0"}
+	seen := map[string]bool{}
+	for _, part := range strings.Split(record, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, value, found := strings.Cut(part, "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		value = strings.TrimSpace(value)
+		if !found {
+			dmarc.addIssue("malformed tag %q", part)
+			continue
+		}
+		if seen[name] {
+			dmarc.addIssue("duplicate tag %s", name)
+			continue
+		}
+		seen[name] = true
+
+		switch name {
+		case "v":
+		case "p", "sp":
+			policy := strings.ToLower(value)
+			if policy != "none" && policy != "quarantine" && policy != "reject" {
+				dmarc.addIssue("invalid %s value %q", name, value)
+				continue
+			}
+			if name == "p" {
+				dmarc.Policy = policy
+			} else {
+				dmarc.SubdomainPolicy = policy
+			}
+		case "pct":
+			pct, err := strconv.Atoi(value)
+			if err != nil || pct < 0 || pct > 100 {
+				dmarc.addIssue("invalid pct value %q", value)
+				continue
+			}
+			dmarc.Percent = pct
+		case "rua", "ruf":
+			uris := parseDMARCURIs(dmarc, name, value)
+			if name == "rua" {
+				dmarc.RUA = uris
+			} else {
+				dmarc.RUF = uris
+			}
+		case "adkim", "aspf":
+			mode := strings.ToLower(value)
+			if mode != "r" && mode != "s" {
+				dmarc.addIssue("invalid %s value %q", name, value)
+				continue
+			}
+			if name == "adkim" {
+				dmarc.ADKIM = mode
+			} else {
+				dmarc.ASPF = mode
+			}
+		case "fo":
+			for _, option := range strings.Split(value, ":") {
+				if o := strings.TrimSpace(option); o != "0" && o != "1" && o != "d" && o != "s" {
+					dmarc.addIssue("invalid fo value %q", value)
+					break
+				}
+			}
+			dmarc.FailureOptions = value
+		case "rf", "ri":
+		default:
+			dmarc.addIssue("unknown tag %s", name)
+		}
+	}
+
+	if !seen["p"] {
+		dmarc.addIssue("missing required p tag")
+	}
+	if dmarc.SubdomainPolicy == "" {
+		dmarc.SubdomainPolicy = dmarc.Policy
+	}
+	return dmarc, nil
+}
+
+// parseDMARCURIs splits a rua or ruf list and checks every URI is a mailto:
+// or https: URI, optionally followed by a size limit.
+func parseDMARCURIs(dmarc *DMARCRecord, tag, value string) []string {
+	var uris []string
+	for _, uri := range strings.Split(value, ",") {
+		uri = strings.TrimSpace(uri)
+		if uri == "" {
+			continue
+		}
+		lower := strings.ToLower(uri)
+		if !strings.HasPrefix(lower, "mailto:") && !strings.HasPrefix(lower, "https:") {
+			dmarc.addIssue("invalid %s URI %q", tag, uri)
+			continue
+		}
+		uris = append(uris, uri)
+	}
+	return uris
+}
+
+// AnalyzeDMARC fetches and parses the DMARC record of domain using the
+// default resolver.
+func AnalyzeDMARC(domain string) (*DMARCRecord, error) {
+	return defaultResolver.AnalyzeDMARC(domain)
+}
+
+// AnalyzeDMARC fetches and parses the DMARC record of domain. Publishing
+// more than one record makes receivers ignore DMARC altogether, so it is
+// reported as an issue on the first record. A report authorization record
+// that cannot be looked up fails the analysis, as its issue is unknown.
+func (r *Resolver) AnalyzeDMARC(domain string) (*DMARCRecord, error) {
+	txtRecords, err := r.GetTXTRecords("_dmarc." + domain)
+	if err != nil {
+		return nil, err
+	}
+
+	var dmarcRecords []string
+	for _, txt := range txtRecords {
+		if isDMARCRecord(txt) {
+			dmarcRecords = append(dmarcRecords, txt)
+		}
+	}
+	if len(dmarcRecords) == 0 {
+		return nil, fmt.Errorf("no DMARC record found for %s: %w", domain, ErrNoData)
+	}
+
+	dmarc, err := ParseDMARC(dmarcRecords[0])
+	if err != nil {
+		return nil, err
+	}
+	if len(dmarcRecords) > 1 {
+		dmarc.addIssue("%d DMARC records published, receivers will ignore all of them", len(dmarcRecords))
+	}
+
+	for _, uri := range append(append([]string{}, dmarc.RUA...), dmarc.RUF...) {
+		destination := reportDomain(uri)
+		if destination == "" || sameOrganization(domain, destination) {
+			continue
+		}
+		dest, err := r.checkReportAuthorization(domain, uri, destination)
+		if err != nil {
+			return nil, err
+		}
+		if !dest.Authorized {
+			dmarc.addIssue("report destination %s is not authorized: no v=DMARC1 record at %s", uri, reportAuthorizationName(domain, destination))
+		}
+		dmarc.Destinations = append(dmarc.Destinations, dest)
+	}
+	return dmarc, nil
+}
+
+// reportAuthorizationName is the name a destination domain publishes to
+// accept reports for domain.
+func reportAuthorizationName(domain, destination string) string {
+	return domain + "._report._dmarc." + destination
+}
+
+// checkReportAuthorization looks up the authorization record for a single
+// external report URI.
+func (r *Resolver) checkReportAuthorization(domain, uri, destination string) (ReportDestination, error) {
+	dest := ReportDestination{URI: uri, Domain: destination}
+
+	records, err := r.GetTXTRecords(reportAuthorizationName(domain, destination))
+	if err != nil {
+		if IsNotFound(err) {
+			return dest, nil
+		}
+		return dest, err
+	}
+	for _, record := range records {
+		if isDMARCRecord(record) {
+			dest.Authorized = true
+		}
+	}
+	return dest, nil
+}
+
+// reportDomain returns the domain a mailto: or https: report URI delivers
+// to, without any size limit suffix.
+func reportDomain(uri string) string {
+	if i := strings.LastIndex(uri, "!"); i >= 0 {
+		uri = uri[:i]
+	}
+	lower := strings.ToLower(uri)
+	switch {
+	case strings.HasPrefix(lower, "mailto:"):
+		if at := strings.LastIndex(lower, "@"); at >= 0 {
+			return strings.TrimSuffix(lower[at+1:], ".")
+		}
+	case strings.HasPrefix(lower, "https:"):
+		if parsed, err := url.Parse(lower); err == nil {
+			return strings.TrimSuffix(parsed.Hostname(), ".")
+		}
+	}
+	return ""
+}
+
+// sameOrganization reports whether destination is domain itself or one of
+// its parents or subdomains, in which case no authorization is needed.
+func sameOrganization(domain, destination string) bool {
+	domain, destination = canonicalName(domain), canonicalName(destination)
+	return dns.IsSubDomain(domain, destination) || dns.IsSubDomain(destination, domain)
+}
+
+// DescribeDMARCChange explains the difference between two raw DMARC records
+// in words, e.g. "policy weakened from reject to none".
+func DescribeDMARCChange(previous, current string) string {
+	before, _ := ParseDMARC(previous)
+	after, _ := ParseDMARC(current)
+
+	switch {
+	case before == nil && after == nil:
+		return ""
+	case before == nil:
+		return "DMARC record added with policy " + after.PolicyDescription()
+	case after == nil:
+		return "DMARC record removed, policy was " + before.PolicyDescription()
+	}
+
+	var changes []string
+	switch {
+	case after.Strength() < before.Strength():
+		changes = append(changes, fmt.Sprintf("policy weakened from %s to %s", before.PolicyDescription(), after.PolicyDescription()))
+	case after.Strength() > before.Strength():
+		changes = append(changes, fmt.Sprintf("policy strengthened from %s to %s", before.PolicyDescription(), after.PolicyDescription()))
+	case before.PolicyDescription() != after.PolicyDescription():
+		changes = append(changes, fmt.Sprintf("policy changed from %s to %s", before.PolicyDescription(), after.PolicyDescription()))
+	}
+
+	// sp follows p unless set, so only report it when either record sets it
+	if before.SubdomainPolicy != before.Policy || after.SubdomainPolicy != after.Policy {
+		if before.SubdomainPolicy != after.SubdomainPolicy {
+			changes = append(changes, fmt.Sprintf("subdomain policy changed from %s to %s", before.SubdomainPolicy, after.SubdomainPolicy))
+		}
+	}
+
+	for _, tag := range []struct {
+		name          string
+		before, after string
+	}{
+		{"rua", strings.Join(before.RUA, ","), strings.Join(after.RUA, ",")},
+		{"ruf", strings.Join(before.RUF, ","), strings.Join(after.RUF, ",")},
+		{"adkim", before.ADKIM, after.ADKIM},
+		{"aspf", before.ASPF, after.ASPF},
+		{"fo", before.FailureOptions, after.FailureOptions},
+	} {
+		if tag.before != tag.after {
+			changes = append(changes, fmt.Sprintf("%s changed from %q to %q", tag.name, tag.before, tag.after))
+		}
+	}
+	return strings.Join(changes, "\n")
+}
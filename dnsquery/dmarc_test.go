@@ -0,0 +1,140 @@
+package dnsquery
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestParseDMARC_Tags(t *testing.T) {
+	dmarc, err := ParseDMARC("v=DMARC1; p=quarantine; sp=reject; pct=50; rua=mailto:agg@example.com,mailto:dmarc@vendor.example; ruf=mailto:forensic@example.com; adkim=s; fo=1:d")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(dmarc.Issues) != 0 {
+		t.Fatalf("unexpected issues: %v", dmarc.Issues)
+	}
+	if dmarc.Policy != "quarantine" || dmarc.SubdomainPolicy != "reject" || dmarc.Percent != 50 {
+		t.Fatalf("unexpected policy: %+v", dmarc)
+	}
+	if len(dmarc.RUA) != 2 || len(dmarc.RUF) != 1 || dmarc.ADKIM != "s" || dmarc.ASPF != "r" {
+		t.Fatalf("unexpected tags: %+v", dmarc)
+	}
+	if dmarc.Strength() != DMARCStrengthPartial || dmarc.PolicyDescription() != "quarantine at 50%" {
+		t.Fatalf("unexpected strength %s (%s)", dmarc.Strength(), dmarc.PolicyDescription())
+	}
+}
+
+func TestParseDMARC_Issues(t *testing.T) {
+	dmarc, err := ParseDMARC("v=DMARC1; p=block; pct=150; foo=bar; rua=ftp://example.com; rua=mailto:a@example.com")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	issues := strings.Join(dmarc.Issues, "\n")
+	for _, want := range []string{"invalid p value", "invalid pct value", "unknown tag foo", "invalid rua URI", "duplicate tag rua"} {
+		if !strings.Contains(issues, want) {
+			t.Fatalf("expected issue %q, got %v", want, dmarc.Issues)
+		}
+	}
+	if dmarc.Strength() != DMARCStrengthMissing {
+		t.Fatalf("expected missing strength for invalid policy, got %s", dmarc.Strength())
+	}
+
+	if _, err := ParseDMARC("v=spf1 -all"); err == nil {
+		t.Fatalf("expected error for non DMARC record")
+	}
+}
+
+func TestAnalyzeDMARC_DuplicateRecords(t *testing.T) {
+	resolver := zoneResolver(t,
+		`_dmarc.example.com. 300 IN TXT "v=DMARC1; p=reject"`,
+		`_dmarc.example.com. 300 IN TXT "v=DMARC1; p=none"`,
+	)
+
+	dmarc, err := resolver.AnalyzeDMARC("example.com")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if !strings.Contains(strings.Join(dmarc.Issues, "\n"), "2 DMARC records published") {
+		t.Fatalf("expected duplicate record issue, got %v", dmarc.Issues)
+	}
+}
+
+func TestAnalyzeDMARC_ReportAuthorization(t *testing.T) {
+	resolver := zoneResolver(t,
+		`_dmarc.example.com. 300 IN TXT "v=DMARC1; p=reject; rua=mailto:dmarc@example.com,mailto:agg@authorized.example!10m; ruf=mailto:forensic@rogue.example"`,
+		`example.com._report._dmarc.authorized.example. 300 IN TXT "v=DMARC1"`,
+	)
+
+	dmarc, err := resolver.AnalyzeDMARC("example.com")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(dmarc.Destinations) != 2 {
+		t.Fatalf("expected 2 external destinations, got %+v", dmarc.Destinations)
+	}
+	if !dmarc.Destinations[0].Authorized || dmarc.Destinations[0].Domain != "authorized.example" {
+		t.Fatalf("expected authorized.example to be authorized, got %+v", dmarc.Destinations[0])
+	}
+	if dmarc.Destinations[1].Authorized {
+		t.Fatalf("expected rogue.example to be unauthorized")
+	}
+	if len(dmarc.Issues) != 1 || !strings.Contains(dmarc.Issues[0], "example.com._report._dmarc.rogue.example") {
+		t.Fatalf("expected one unauthorized destination issue, got %v", dmarc.Issues)
+	}
+}
+
+func TestAnalyzeDMARC_InternationalizedDomain(t *testing.T) {
+	resolver := zoneResolver(t,
+		`_dmarc.xn--bcher-kva.de. 300 IN TXT "v=DMARC1; p=reject; rua=mailto:dmarc@xn--bcher-kva.de; ruf=mailto:forensic@bücher.de"`,
+	)
+
+	for _, domain := range []string{"bücher.de", "xn--bcher-kva.de"} {
+		dmarc, err := resolver.AnalyzeDMARC(domain)
+		if err != nil {
+			t.Fatalf("%s: unexpected err: %v", domain, err)
+		}
+		if len(dmarc.Destinations) != 0 || len(dmarc.Issues) != 0 {
+			t.Fatalf("%s: expected own reports to need no authorization, got %+v", domain, dmarc)
+		}
+	}
+}
+
+func TestAnalyzeDMARC_ReportAuthorizationLookupFails(t *testing.T) {
+	resolver := NewResolver(Config{
+		Servers: []string{"192.0.2.1"},
+		Exchanger: exchangeFunc(func(msg *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+			if msg.Question[0].Name == "_dmarc.example.com." {
+				hdr := dns.RR_Header{Name: "_dmarc.example.com.", Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: 300}
+				return answer(msg, &dns.TXT{Hdr: hdr, Txt: []string{"v=DMARC1; p=reject; rua=mailto:agg@vendor.example"}}), 0, nil
+			}
+			return rcode(msg, dns.RcodeServerFailure), 0, nil
+		}),
+	})
+
+	if _, err := resolver.AnalyzeDMARC("example.com"); !errors.Is(err, ErrServFail) {
+		t.Fatalf("expected SERVFAIL error, got %v", err)
+	}
+}
+
+func TestDescribeDMARCChange(t *testing.T) {
+	cases := []struct {
+		previous, current, want string
+	}{
+		{"v=DMARC1; p=reject", "v=DMARC1; p=none", "policy weakened from reject to none"},
+		{"v=DMARC1; p=none", "v=DMARC1; p=quarantine", "policy strengthened from none to quarantine"},
+		{"v=DMARC1; p=reject", "v=DMARC1; p=reject; pct=20", "policy weakened from reject to reject at 20%"},
+		{"v=DMARC1; p=reject; rua=mailto:a@example.com", "v=DMARC1; p=reject; rua=mailto:b@example.com", `rua changed from "mailto:a@example.com" to "mailto:b@example.com"`},
+		{"v=DMARC1; p=reject", "", "DMARC record removed, policy was reject"},
+		{"", "v=DMARC1; p=none", "DMARC record added with policy none"},
+	}
+
+	for _, tc := range cases {
+		if got := DescribeDMARCChange(tc.previous, tc.current); got != tc.want {
+			t.Fatalf("DescribeDMARCChange(%q, %q) = %q, want %q", tc.previous, tc.current, got, tc.want)
+		}
+	}
+}
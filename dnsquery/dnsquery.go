@@ -1,54 +1,41 @@
 package dnsquery
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net"
 	"regexp"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/likexian/whois"
 	"github.com/miekg/dns"
 )
 
-// DNSQuery performs a DNS query for a given domain and record type.
-func DNSQuery(domain string, qtype uint16) ([]dns.RR, error) {
-	// default implementation uses miekg/dns client; overrideable for tests
-	return dnsQueryImpl(domain, qtype)
-}
-
-// dnsQueryImpl is the actual implementation used by DNSQuery. It's a variable
-// so tests can replace it.
-var dnsQueryImpl = func(domain string, qtype uint16) ([]dns.RR, error) {
-	client := new(dns.Client)
-	msg := new(dns.Msg)
-	msg.SetQuestion(dns.Fqdn(domain), qtype)
-	msg.RecursionDesired = true
-	msg.AuthenticatedData = true // Set the AD bit
+// whoisDialTimeout bounds connecting to a WHOIS server when the context has
+// no earlier deadline.
+const whoisDialTimeout = 30 * time.Second
 
-	response, _, err := client.Exchange(msg, "1.1.1.1:53") // Using Cloudflare's public DNS server
-	if err != nil {
-		return nil, err
-	}
+// defaultResolver backs the package level lookup functions.
+var defaultResolver = NewResolver(DefaultConfig())
 
-	if response.Rcode != dns.RcodeSuccess {
-		return nil, fmt.Errorf("invalid answer name %s after MX query for %s", domain, domain)
-	}
-	return response.Answer, nil
+// DNSQuery performs a DNS query for a given domain and record type using the
+// default resolver.
+func DNSQuery(domain string, qtype uint16) ([]dns.RR, error) {
+	return defaultResolver.Query(domain, qtype)
 }
 
+// GetTXTRecords fetches TXT records for a domain using the default resolver.
 func GetTXTRecords(domain string) ([]string, error) {
-	return lookupTXTImpl(domain)
-}
-
-// lookupTXTImpl wraps net.LookupTXT so tests can override it.
-var lookupTXTImpl = func(domain string) ([]string, error) {
-	return net.LookupTXT(domain)
+	return defaultResolver.GetTXTRecords(domain)
 }
 
-// GetTXTRecords fetches TXT records for a domain.
-func GetTXTRecords_b1(domain string) ([]string, error) {
-	records, err := DNSQuery(domain, dns.TypeTXT)
+// GetTXTRecords fetches TXT records for a domain. The character strings of
+// each record are concatenated as RFC 7208 section 3.3 requires.
+func (r *Resolver) GetTXTRecords(domain string) ([]string, error) {
+	records, err := r.Query(domain, dns.TypeTXT)
 	if err != nil {
 		return nil, err
 	}
@@ -56,15 +43,20 @@ func GetTXTRecords_b1(domain string) ([]string, error) {
 	var txtRecords []string
 	for _, record := range records {
 		if txt, ok := record.(*dns.TXT); ok {
-			txtRecords = append(txtRecords, strings.Join(txt.Txt, " "))
+			txtRecords = append(txtRecords, strings.Join(txt.Txt, ""))
 		}
 	}
 	return txtRecords, nil
 }
 
-// GetNSRecords fetches NS records for a domain.
+// GetNSRecords fetches NS records for a domain using the default resolver.
 func GetNSRecords(domain string) ([]string, error) {
-	records, err := DNSQuery(domain, dns.TypeNS)
+	return defaultResolver.GetNSRecords(domain)
+}
+
+// GetNSRecords fetches NS records for a domain.
+func (r *Resolver) GetNSRecords(domain string) ([]string, error) {
+	records, err := r.Query(domain, dns.TypeNS)
 	if err != nil {
 		return nil, err
 	}
@@ -78,10 +70,100 @@ func GetNSRecords(domain string) ([]string, error) {
 	return nsRecords, nil
 }
 
-// GetDMARCRecord fetches the DMARC record for a domain.
+// MXRecord is a single mail exchanger with its preference.
+type MXRecord struct {
+	Preference uint16
+	Host       string
+}
+
+func (m MXRecord) String() string {
+	return fmt.Sprintf("%d %s", m.Preference, m.Host)
+}
+
+// GetMXRecords fetches MX records for a domain using the default resolver.
+func GetMXRecords(domain string) ([]MXRecord, error) {
+	return defaultResolver.GetMXRecords(domain)
+}
+
+// GetMXRecords fetches MX records for a domain, ordered by preference and
+// then host name.
+func (r *Resolver) GetMXRecords(domain string) ([]MXRecord, error) {
+	records, err := r.Query(domain, dns.TypeMX)
+	if err != nil {
+		return nil, err
+	}
+
+	var mxRecords []MXRecord
+	for _, record := range records {
+		if mx, ok := record.(*dns.MX); ok {
+			mxRecords = append(mxRecords, MXRecord{Preference: mx.Preference, Host: strings.ToLower(dns.Fqdn(mx.Mx))})
+		}
+	}
+	sort.Slice(mxRecords, func(i, j int) bool {
+		if mxRecords[i].Preference != mxRecords[j].Preference {
+			return mxRecords[i].Preference < mxRecords[j].Preference
+		}
+		return mxRecords[i].Host < mxRecords[j].Host
+	})
+	return mxRecords, nil
+}
+
+// FormatMXRecords joins MX records into the normalized form stored on the
+// domain, e.g. "10 mx1.example.com., 20 mx2.example.com.".
+func FormatMXRecords(records []MXRecord) string {
+	values := make([]string, 0, len(records))
+	for _, record := range records {
+		values = append(values, record.String())
+	}
+	return strings.Join(values, ", ")
+}
+
+// GetAddresses fetches the A and AAAA records of a name using the default
+// resolver.
+func GetAddresses(name string) ([]string, error) {
+	return defaultResolver.GetAddresses(name)
+}
+
+// GetAddresses fetches the A and AAAA records of a name and returns the
+// addresses sorted. A name with only one of the two families is not an
+// error; a name with neither returns the lookup error of the A query.
+func (r *Resolver) GetAddresses(name string) ([]string, error) {
+	var addresses []string
+
+	v4, errV4 := r.Query(name, dns.TypeA)
+	if errV4 != nil && !IsNotFound(errV4) {
+		return nil, errV4
+	}
+	v6, errV6 := r.Query(name, dns.TypeAAAA)
+	if errV6 != nil && !IsNotFound(errV6) {
+		return nil, errV6
+	}
+	if errV4 != nil && errV6 != nil {
+		return nil, errV4
+	}
+
+	for _, record := range append(v4, v6...) {
+		switch rr := record.(type) {
+		case *dns.A:
+			addresses = append(addresses, rr.A.String())
+		case *dns.AAAA:
+			addresses = append(addresses, rr.AAAA.String())
+		}
+	}
+	sort.Strings(addresses)
+	return addresses, nil
+}
+
+// GetDMARCRecord fetches the DMARC record for a domain using the default
+// resolver.
 func GetDMARCRecord(domain string) (string, error) {
+	return defaultResolver.GetDMARCRecord(domain)
+}
+
+// GetDMARCRecord fetches the DMARC record for a domain.
+func (r *Resolver) GetDMARCRecord(domain string) (string, error) {
 	dmarcDomain := "_dmarc." + domain
-	records, err := GetTXTRecords(dmarcDomain)
+	records, err := r.GetTXTRecords(dmarcDomain)
 	if err != nil {
 		return "", err
 	}
@@ -91,12 +173,18 @@ func GetDMARCRecord(domain string) (string, error) {
 			return record, nil
 		}
 	}
-	return "", fmt.Errorf("no DMARC record found for %s", domain)
+	return "", fmt.Errorf("no DMARC record found for %s: %w", domain, ErrNoData)
 }
 
-// GetSPFRecord fetches the SPF record for a domain.
+// GetSPFRecord fetches the SPF record for a domain using the default
+// resolver.
 func GetSPFRecord(domain string) (string, error) {
-	records, err := GetTXTRecords(domain)
+	return defaultResolver.GetSPFRecord(domain)
+}
+
+// GetSPFRecord fetches the SPF record for a domain.
+func (r *Resolver) GetSPFRecord(domain string) (string, error) {
+	records, err := r.GetTXTRecords(domain)
 	if err != nil {
 		return "", err
 	}
@@ -106,47 +194,87 @@ func GetSPFRecord(domain string) (string, error) {
 			return record, nil
 		}
 	}
-	return "", fmt.Errorf("no SPF record found for %s", domain)
+	return "", fmt.Errorf("no SPF record found for %s: %w", domain, ErrNoData)
 }
 
-// GetDKIMRecord fetches the DKIM record for a domain.
+// GetDKIMRecord fetches the DKIM record for a domain using the default
+// resolver.
 func GetDKIMRecord(domain, selector string) (string, error) {
+	return defaultResolver.GetDKIMRecord(domain, selector)
+}
+
+// GetDKIMRecord fetches the DKIM record for a domain. The v= tag is optional
+// (RFC 6376 section 3.6.1), so the key record is the one with a p= tag.
+func (r *Resolver) GetDKIMRecord(domain, selector string) (string, error) {
 	dkimDomain := selector + "._domainkey." + domain
-	records, err := GetTXTRecords(dkimDomain)
+	records, err := r.GetTXTRecords(dkimDomain)
 	if err != nil {
 		return "", err
 	}
 
 	for _, record := range records {
-		if strings.HasPrefix(record, "v=DKIM1") {
+		if _, ok := parseTags(record)["p"]; ok {
 			return record, nil
 		}
 	}
-	return "", fmt.Errorf("no DKIM record found for %s with selector %s", domain, selector)
+	return "", fmt.Errorf("no DKIM record found for %s with selector %s: %w", domain, selector, ErrNoData)
 }
 
 func GetWhois(domain string) (string, error) {
-	// Perform the WHOIS query
-	return whoisImpl(domain)
+	return GetWhoisContext(context.Background(), domain)
 }
 
-// whoisImpl wraps whois.Whois so tests can override it.
-var whoisImpl = func(domain string) (string, error) {
-	result, err := whois.Whois(domain)
+// GetWhoisContext performs the WHOIS query, giving up when ctx is done.
+// Registries are asked for the A-label form of domain.
+func GetWhoisContext(ctx context.Context, domain string) (string, error) {
+	name, err := ToASCII(domain)
+	if err != nil {
+		return "", err
+	}
+	return whoisImpl(ctx, name)
+}
+
+// whoisImpl queries WHOIS with a client bound to ctx so tests can override it.
+var whoisImpl = func(ctx context.Context, domain string) (string, error) {
+	client := whois.NewClient().SetDialer(&contextDialer{ctx: ctx})
+	if deadline, ok := ctx.Deadline(); ok {
+		client.SetTimeout(time.Until(deadline))
+	}
+	result, err := client.Whois(domain)
 	if err != nil {
 		log.Println("Error fetching WHOIS information:", err)
 	}
 	return result, err
 }
 
+// contextDialer connects the WHOIS client within ctx and closes the
+// connection when ctx is done, which the client cannot do itself.
+type contextDialer struct {
+	ctx context.Context
+}
+
+func (d *contextDialer) Dial(network, address string) (net.Conn, error) {
+	conn, err := (&net.Dialer{Timeout: whoisDialTimeout}).DialContext(d.ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	context.AfterFunc(d.ctx, func() { conn.Close() })
+	return conn, nil
+}
+
 func GetExpirationDate(domain string) (string, error) {
+	return GetExpirationDateContext(context.Background(), domain)
+}
+
+// GetExpirationDateContext is GetExpirationDate bound to ctx.
+func GetExpirationDateContext(ctx context.Context, domain string) (string, error) {
 	patterns := []string{
 		`Registry Expiry Date:\s*(.*)`,                   // Common for many TLDs
 		`Registrar Registration Expiration Date:\s*(.*)`, // Some other TLDs
 		`Expiration Date:\s*(.*)`,                        // General pattern
 	}
 
-	result, err := GetWhois(domain)
+	result, err := GetWhoisContext(ctx, domain)
 	if err != nil {
 		return "", err
 	}
@@ -171,6 +299,11 @@ func GetExpirationDate(domain string) (string, error) {
 }
 
 func GetAllDatesFromWhois(domain string) map[string]string {
+	return GetAllDatesFromWhoisContext(context.Background(), domain)
+}
+
+// GetAllDatesFromWhoisContext is GetAllDatesFromWhois bound to ctx.
+func GetAllDatesFromWhoisContext(ctx context.Context, domain string) map[string]string {
 	patterns := map[string]string{
 		"creationDate":          `Creation Date:\s*(.*)`,                          // Common for many TLDs
 		"expirationDate":        `Registry Expiry Date:\s*(.*)`,                   // Common for many TLDs
@@ -184,7 +317,7 @@ func GetAllDatesFromWhois(domain string) map[string]string {
 	expirationDate := ""
 	registrar := ""
 
-	result, err := GetWhois(domain)
+	result, err := GetWhoisContext(ctx, domain)
 	if err != nil {
 		return map[string]string{}
 	}
@@ -220,9 +353,16 @@ func GetAllDatesFromWhois(domain string) map[string]string {
 
 }
 
+// GetDomainDetails prints every record the package knows about for domain
+// using the default resolver.
 func GetDomainDetails(domain string, dkim_selector []string) {
+	defaultResolver.GetDomainDetails(domain, dkim_selector)
+}
+
+// GetDomainDetails prints every record the package knows about for domain.
+func (r *Resolver) GetDomainDetails(domain string, dkim_selector []string) {
 
-	txtRecords, err := GetTXTRecords(domain)
+	txtRecords, err := r.GetTXTRecords(domain)
 	if err != nil {
 		log.Fatalf("Failed to get TXT records: %v", err)
 	}
@@ -231,20 +371,20 @@ func GetDomainDetails(domain string, dkim_selector []string) {
 	}
 	fmt.Printf("TXT records for %s:\n%v\n\n", domain, txtRecords)
 
-	nsRecords, err := GetNSRecords(domain)
+	nsRecords, err := r.GetNSRecords(domain)
 	if err != nil {
 		log.Fatalf("Failed to get NS records: %v", err)
 	}
 	fmt.Printf("NS records for %s:\n%v\n\n", domain, nsRecords)
 
-	dmarcRecord, err := GetDMARCRecord(domain)
+	dmarcRecord, err := r.GetDMARCRecord(domain)
 	if err != nil {
 		log.Printf("Failed to get DMARC record: %v", err)
 	} else {
 		fmt.Printf("DMARC record for %s:\n%s\n\n", domain, dmarcRecord)
 	}
 
-	spfRecord, err := GetSPFRecord(domain)
+	spfRecord, err := r.GetSPFRecord(domain)
 	if err != nil {
 		log.Printf("Failed to get SPF record: %v", err)
 	} else {
@@ -252,7 +392,7 @@ func GetDomainDetails(domain string, dkim_selector []string) {
 	}
 
 	for _, selector := range dkim_selector {
-		dkimRecord, err := GetDKIMRecord(domain, selector)
+		dkimRecord, err := r.GetDKIMRecord(domain, selector)
 		if err != nil {
 			log.Printf("Failed to get DKIM record: %v", err)
 		} else {
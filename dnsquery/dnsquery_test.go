@@ -1,24 +1,43 @@
 package dnsquery
 
 import (
+	"context"
 	"errors"
+	"net"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/miekg/dns"
 )
 
-func TestGetTXTRecords_UsesLookupTXTImpl(t *testing.T) {
-	old := lookupTXTImpl
-	defer func() { lookupTXTImpl = old }()
+// txtResolver returns a Resolver that answers TXT queries for name with
+// records and fails the test for any other question.
+func txtResolver(t *testing.T, name string, records ...string) *Resolver {
+	t.Helper()
+	return NewResolver(Config{
+		Servers: []string{"192.0.2.1"},
+		Exchanger: exchangeFunc(func(msg *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+			question := msg.Question[0]
+			if question.Name != dns.Fqdn(name) {
+				t.Fatalf("unexpected domain: %s", question.Name)
+			}
+			if question.Qtype != dns.TypeTXT {
+				return nil, 0, errors.New("unexpected qtype")
+			}
+			var rrs []dns.RR
+			for _, record := range records {
+				rrs = append(rrs, &dns.TXT{Hdr: dns.RR_Header{Name: question.Name, Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: 300}, Txt: []string{record}})
+			}
+			return answer(msg, rrs...), 0, nil
+		}),
+	})
+}
 
-	lookupTXTImpl = func(domain string) ([]string, error) {
-		if domain != "example.com" {
-			t.Fatalf("unexpected domain: %s", domain)
-		}
-		return []string{"v=spf1 include:_spf.example.com ~all", "some other txt"}, nil
-	}
+func TestGetTXTRecords_UsesResolver(t *testing.T) {
+	resolver := txtResolver(t, "example.com", "v=spf1 include:_spf.example.com ~all", "some other txt")
 
-	recs, err := GetTXTRecords("example.com")
+	recs, err := resolver.GetTXTRecords("example.com")
 	if err != nil {
 		t.Fatalf("unexpected err: %v", err)
 	}
@@ -28,14 +47,9 @@ func TestGetTXTRecords_UsesLookupTXTImpl(t *testing.T) {
 }
 
 func TestGetSPFRecord_FindsRecord(t *testing.T) {
-	old := lookupTXTImpl
-	defer func() { lookupTXTImpl = old }()
-
-	lookupTXTImpl = func(domain string) ([]string, error) {
-		return []string{"v=spf1 include:_spf.example.com ~all", "other"}, nil
-	}
+	resolver := txtResolver(t, "example.com", "v=spf1 include:_spf.example.com ~all", "other")
 
-	rec, err := GetSPFRecord("example.com")
+	rec, err := resolver.GetSPFRecord("example.com")
 	if err != nil {
 		t.Fatalf("unexpected err: %v", err)
 	}
@@ -45,32 +59,18 @@ func TestGetSPFRecord_FindsRecord(t *testing.T) {
 }
 
 func TestGetSPFRecord_NotFound(t *testing.T) {
-	old := lookupTXTImpl
-	defer func() { lookupTXTImpl = old }()
+	resolver := txtResolver(t, "example.com", "not spf")
 
-	lookupTXTImpl = func(domain string) ([]string, error) {
-		return []string{"not spf"}, nil
-	}
-
-	_, err := GetSPFRecord("example.com")
+	_, err := resolver.GetSPFRecord("example.com")
 	if err == nil {
 		t.Fatalf("expected error when spf not found")
 	}
 }
 
 func TestGetDMARCRecord_FindsRecord(t *testing.T) {
-	old := lookupTXTImpl
-	defer func() { lookupTXTImpl = old }()
-
-	lookupTXTImpl = func(domain string) ([]string, error) {
-		// domain will be _dmarc.example.com
-		if domain != "_dmarc.example.com" {
-			t.Fatalf("unexpected domain: %s", domain)
-		}
-		return []string{"v=DMARC1; p=none; rua=mailto:postmaster@example.com"}, nil
-	}
+	resolver := txtResolver(t, "_dmarc.example.com", "v=DMARC1; p=none; rua=mailto:postmaster@example.com")
 
-	rec, err := GetDMARCRecord("example.com")
+	rec, err := resolver.GetDMARCRecord("example.com")
 	if err != nil {
 		t.Fatalf("unexpected err: %v", err)
 	}
@@ -80,18 +80,9 @@ func TestGetDMARCRecord_FindsRecord(t *testing.T) {
 }
 
 func TestGetDKIMRecord_FindsRecord(t *testing.T) {
-	old := lookupTXTImpl
-	defer func() { lookupTXTImpl = old }()
-
-	lookupTXTImpl = func(domain string) ([]string, error) {
-		// selector._domainkey.example.com
-		if domain != "selector._domainkey.example.com" {
-			t.Fatalf("unexpected domain: %s", domain)
-		}
-		return []string{"v=DKIM1; k=rsa; p=abcd"}, nil
-	}
+	resolver := txtResolver(t, "selector._domainkey.example.com", "v=DKIM1; k=rsa; p=abcd")
 
-	rec, err := GetDKIMRecord("example.com", "selector")
+	rec, err := resolver.GetDKIMRecord("example.com", "selector")
 	if err != nil {
 		t.Fatalf("unexpected err: %v", err)
 	}
@@ -100,44 +91,55 @@ func TestGetDKIMRecord_FindsRecord(t *testing.T) {
 	}
 }
 
-func TestGetTXTRecords_b1_ParsesDNSRecords(t *testing.T) {
-	old := dnsQueryImpl
-	defer func() { dnsQueryImpl = old }()
+func TestGetDKIMRecord_WithoutVersionTag(t *testing.T) {
+	resolver := txtResolver(t, "selector._domainkey.example.com", "unrelated text", "k=rsa; p=abcd")
 
-	// create a fake TXT RR
-	txt := &dns.TXT{Hdr: dns.RR_Header{Name: "example.com.", Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: 300}, Txt: []string{"hello", "world"}}
-	dnsQueryImpl = func(domain string, qtype uint16) ([]dns.RR, error) {
-		if qtype != dns.TypeTXT {
-			return nil, errors.New("unexpected qtype")
-		}
-		return []dns.RR{txt}, nil
+	rec, err := resolver.GetDKIMRecord("example.com", "selector")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if rec != "k=rsa; p=abcd" {
+		t.Fatalf("expected the key record without v=, got %q", rec)
 	}
+}
+
+func TestGetTXTRecords_ConcatenatesCharacterStrings(t *testing.T) {
+	txt := &dns.TXT{Hdr: dns.RR_Header{Name: "example.com.", Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: 300}, Txt: []string{"hello", "world"}}
+	resolver := NewResolver(Config{
+		Servers: []string{"192.0.2.1"},
+		Exchanger: exchangeFunc(func(msg *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+			if msg.Question[0].Qtype != dns.TypeTXT {
+				return nil, 0, errors.New("unexpected qtype")
+			}
+			return answer(msg, txt), 0, nil
+		}),
+	})
 
-	recs, err := GetTXTRecords_b1("example.com")
+	recs, err := resolver.GetTXTRecords("example.com")
 	if err != nil {
 		t.Fatalf("unexpected err: %v", err)
 	}
 	if len(recs) != 1 {
 		t.Fatalf("expected 1 record, got %d", len(recs))
 	}
-	if recs[0] != "hello world" {
+	if recs[0] != "helloworld" {
 		t.Fatalf("unexpected txt join result: %s", recs[0])
 	}
 }
 
 func TestGetNSRecords_ParsesDNSRecords(t *testing.T) {
-	old := dnsQueryImpl
-	defer func() { dnsQueryImpl = old }()
-
 	ns := &dns.NS{Hdr: dns.RR_Header{Name: "example.com.", Rrtype: dns.TypeNS, Class: dns.ClassINET, Ttl: 300}, Ns: "ns1.example.com."}
-	dnsQueryImpl = func(domain string, qtype uint16) ([]dns.RR, error) {
-		if qtype != dns.TypeNS {
-			return nil, errors.New("unexpected qtype")
-		}
-		return []dns.RR{ns}, nil
-	}
+	resolver := NewResolver(Config{
+		Servers: []string{"192.0.2.1"},
+		Exchanger: exchangeFunc(func(msg *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+			if msg.Question[0].Qtype != dns.TypeNS {
+				return nil, 0, errors.New("unexpected qtype")
+			}
+			return answer(msg, ns), 0, nil
+		}),
+	})
 
-	recs, err := GetNSRecords("example.com")
+	recs, err := resolver.GetNSRecords("example.com")
 	if err != nil {
 		t.Fatalf("unexpected err: %v", err)
 	}
@@ -149,11 +151,136 @@ func TestGetNSRecords_ParsesDNSRecords(t *testing.T) {
 	}
 }
 
+func TestQuery_ClassifiesErrors(t *testing.T) {
+	cases := []struct {
+		name  string
+		reply func(msg *dns.Msg) (*dns.Msg, error)
+		kind  ErrorKind
+	}{
+		{"nxdomain", func(msg *dns.Msg) (*dns.Msg, error) { return rcode(msg, dns.RcodeNameError), nil }, KindNXDomain},
+		{"nodata", func(msg *dns.Msg) (*dns.Msg, error) { return answer(msg), nil }, KindNoData},
+		{"servfail", func(msg *dns.Msg) (*dns.Msg, error) { return rcode(msg, dns.RcodeServerFailure), nil }, KindServFail},
+		{"timeout", func(msg *dns.Msg) (*dns.Msg, error) { return nil, &net.OpError{Op: "read", Err: timeoutError{}} }, KindTimeout},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resolver := NewResolver(Config{
+				Servers: []string{"192.0.2.1"},
+				Retries: 0,
+				Exchanger: exchangeFunc(func(msg *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+					reply, err := tc.reply(msg)
+					return reply, 0, err
+				}),
+			})
+
+			_, err := resolver.GetTXTRecords("example.com")
+			var lookupErr *LookupError
+			if !errors.As(err, &lookupErr) {
+				t.Fatalf("expected *LookupError, got %v", err)
+			}
+			if lookupErr.Kind != tc.kind {
+				t.Fatalf("expected kind %s, got %s", tc.kind, lookupErr.Kind)
+			}
+		})
+	}
+}
+
+func TestLookupErrors_MatchSentinels(t *testing.T) {
+	nxdomain := &LookupError{Kind: KindNXDomain, Name: "example.com", Qtype: dns.TypeNS}
+	if !errors.Is(nxdomain, ErrNXDomain) || !IsNotFound(nxdomain) {
+		t.Fatalf("expected NXDOMAIN to match ErrNXDomain and IsNotFound")
+	}
+
+	timeout := &LookupError{Kind: KindTimeout, Name: "example.com", Qtype: dns.TypeNS, Err: timeoutError{}}
+	if !errors.Is(timeout, ErrTimeout) || IsNotFound(timeout) {
+		t.Fatalf("expected timeout to match ErrTimeout only")
+	}
+
+	servfail := &LookupError{Kind: KindServFail, Name: "example.com", Qtype: dns.TypeNS}
+	if errors.Is(servfail, ErrNXDomain) || IsNotFound(servfail) {
+		t.Fatalf("expected SERVFAIL not to be reported as not found")
+	}
+}
+
+func TestGetSPFRecord_NotFoundIsNoData(t *testing.T) {
+	resolver := txtResolver(t, "example.com", "not spf")
+
+	_, err := resolver.GetSPFRecord("example.com")
+	if !errors.Is(err, ErrNoData) {
+		t.Fatalf("expected ErrNoData, got %v", err)
+	}
+}
+
+func TestGetMXRecords_SortsAndFormats(t *testing.T) {
+	resolver := NewResolver(Config{
+		Servers: []string{"192.0.2.1"},
+		Exchanger: exchangeFunc(func(msg *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+			if msg.Question[0].Qtype != dns.TypeMX {
+				return nil, 0, errors.New("unexpected qtype")
+			}
+			hdr := dns.RR_Header{Name: "example.com.", Rrtype: dns.TypeMX, Class: dns.ClassINET, Ttl: 300}
+			return answer(msg,
+				&dns.MX{Hdr: hdr, Preference: 20, Mx: "MX2.example.com."},
+				&dns.MX{Hdr: hdr, Preference: 10, Mx: "mx1.example.com."}), 0, nil
+		}),
+	})
+
+	recs, err := resolver.GetMXRecords("example.com")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if got := FormatMXRecords(recs); got != "10 mx1.example.com., 20 mx2.example.com." {
+		t.Fatalf("unexpected mx set: %s", got)
+	}
+}
+
+func TestGetAddresses_CombinesFamilies(t *testing.T) {
+	resolver := NewResolver(Config{
+		Servers: []string{"192.0.2.1"},
+		Exchanger: exchangeFunc(func(msg *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+			question := msg.Question[0]
+			hdr := dns.RR_Header{Name: question.Name, Rrtype: question.Qtype, Class: dns.ClassINET, Ttl: 300}
+			switch question.Qtype {
+			case dns.TypeA:
+				return answer(msg,
+					&dns.A{Hdr: hdr, A: net.ParseIP("192.0.2.20")},
+					&dns.A{Hdr: hdr, A: net.ParseIP("192.0.2.10")}), 0, nil
+			case dns.TypeAAAA:
+				return answer(msg, &dns.AAAA{Hdr: hdr, AAAA: net.ParseIP("2001:db8::1")}), 0, nil
+			}
+			return nil, 0, errors.New("unexpected qtype")
+		}),
+	})
+
+	addresses, err := resolver.GetAddresses("www.example.com")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if strings.Join(addresses, ", ") != "192.0.2.10, 192.0.2.20, 2001:db8::1" {
+		t.Fatalf("unexpected addresses: %v", addresses)
+	}
+}
+
+func TestGetAddresses_NoRecords(t *testing.T) {
+	resolver := NewResolver(Config{
+		Servers: []string{"192.0.2.1"},
+		Exchanger: exchangeFunc(func(msg *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+			return rcode(msg, dns.RcodeNameError), 0, nil
+		}),
+	})
+
+	_, err := resolver.GetAddresses("www.example.com")
+	if !errors.Is(err, ErrNXDomain) {
+		t.Fatalf("expected ErrNXDomain, got %v", err)
+	}
+}
+
 func TestGetExpirationDate_ParsesWhois(t *testing.T) {
 	old := whoisImpl
 	defer func() { whoisImpl = old }()
 
-	whoisImpl = func(domain string) (string, error) {
+	whoisImpl = func(ctx context.Context, domain string) (string, error) {
 		return "Registrar: Example Registrar\nRegistry Expiry Date: 2026-10-30T12:00:00Z\nCreation Date: 2020-01-01T00:00:00Z", nil
 	}
 
@@ -170,7 +297,7 @@ func TestGetAllDatesFromWhois_ParsesWhois(t *testing.T) {
 	old := whoisImpl
 	defer func() { whoisImpl = old }()
 
-	whoisImpl = func(domain string) (string, error) {
+	whoisImpl = func(ctx context.Context, domain string) (string, error) {
 		return "Registrar: Example Registrar\nRegistry Expiry Date: 2026-10-30T12:00:00Z\nCreation Date: 2020-01-01T00:00:00Z", nil
 	}
 
@@ -0,0 +1,104 @@
+package dnsquery
+
+import (
+	"github.com/miekg/dns"
+)
+
+// DNSSECStatus describes the DNSSEC state of a domain.
+type DNSSECStatus string
+
+const (
+	// DNSSECUnsigned means there is neither a DS record at the parent nor a
+	// DNSKEY in the zone.
+	DNSSECUnsigned DNSSECStatus = "unsigned"
+	// DNSSECSecure means the zone is signed and the resolver validated it.
+	DNSSECSecure DNSSECStatus = "secure"
+	// DNSSECBogus means the zone is signed but validation fails, so
+	// validating resolvers answer SERVFAIL for it.
+	DNSSECBogus DNSSECStatus = "bogus"
+	// DNSSECDSWithoutDNSKEY means the parent publishes a DS record but the
+	// zone has no DNSKEY, which breaks resolution for validating resolvers.
+	DNSSECDSWithoutDNSKEY DNSSECStatus = "ds-without-dnskey"
+	// DNSSECDNSKEYWithoutDS means the zone is signed but the parent has no
+	// DS record, so the signatures are never validated.
+	DNSSECDNSKEYWithoutDS DNSSECStatus = "dnskey-without-ds"
+	// DNSSECUnvalidated means the zone is signed and delegated securely but
+	// the configured resolver does not validate, so the result is unknown.
+	DNSSECUnvalidated DNSSECStatus = "signed-unvalidated"
+)
+
+// GetDNSSECStatus reports the DNSSEC status of domain using the default
+// resolver.
+func GetDNSSECStatus(domain string) (DNSSECStatus, error) {
+	return defaultResolver.GetDNSSECStatus(domain)
+}
+
+// GetDNSSECStatus reports the DNSSEC status of domain. It checks for DS and
+// DNSKEY records with checking disabled, then asks for the SOA with
+// checking enabled and reads the AD bit or SERVFAIL from the reply. A
+// SERVFAIL only means bogus when the SOA resolves with checking disabled;
+// otherwise the upstream is failing and a ServFail LookupError is returned.
+func (r *Resolver) GetDNSSECStatus(domain string) (DNSSECStatus, error) {
+	hasDS, err := r.hasDNSSECRecords(domain, dns.TypeDS)
+	if err != nil {
+		return "", err
+	}
+	hasDNSKEY, err := r.hasDNSSECRecords(domain, dns.TypeDNSKEY)
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case !hasDS && !hasDNSKEY:
+		return DNSSECUnsigned, nil
+	case !hasDS:
+		return DNSSECDNSKEYWithoutDS, nil
+	case !hasDNSKEY:
+		return DNSSECDSWithoutDNSKEY, nil
+	}
+
+	msg, err := r.newQuery(domain, dns.TypeSOA)
+	if err != nil {
+		return "", lookupError(domain, dns.TypeSOA, nil, err)
+	}
+	msg.IsEdns0().SetDo()
+	response, err := r.exchange(msg)
+	if err != nil {
+		return "", lookupError(domain, dns.TypeSOA, nil, err)
+	}
+
+	switch {
+	case response.Rcode == dns.RcodeServerFailure:
+		if found, err := r.hasDNSSECRecords(domain, dns.TypeSOA); err != nil || !found {
+			return "", lookupError(domain, dns.TypeSOA, response, nil)
+		}
+		return DNSSECBogus, nil
+	case response.Rcode != dns.RcodeSuccess:
+		return "", lookupError(domain, dns.TypeSOA, response, nil)
+	case response.AuthenticatedData:
+		return DNSSECSecure, nil
+	}
+	return DNSSECUnvalidated, nil
+}
+
+// hasDNSSECRecords reports whether domain has records of qtype. Checking is
+// disabled so a broken chain does not hide the records behind SERVFAIL.
+func (r *Resolver) hasDNSSECRecords(domain string, qtype uint16) (bool, error) {
+	msg, err := r.newQuery(domain, qtype)
+	if err != nil {
+		return false, lookupError(domain, qtype, nil, err)
+	}
+	msg.CheckingDisabled = true
+	msg.IsEdns0().SetDo()
+
+	response, err := r.exchange(msg)
+	if err != nil {
+		return false, lookupError(domain, qtype, nil, err)
+	}
+
+	switch response.Rcode {
+	case dns.RcodeSuccess, dns.RcodeNameError:
+		return hasType(response.Answer, qtype), nil
+	}
+	return false, lookupError(domain, qtype, response, nil)
+}
@@ -0,0 +1,81 @@
+package dnsquery
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestGetDNSSECStatus(t *testing.T) {
+	cases := []struct {
+		name      string
+		ds        bool
+		dnskey    bool
+		validated int // -2 SERVFAIL even with CD, -1 SERVFAIL, 0 no AD bit, 1 AD bit
+		want      DNSSECStatus
+		wantErr   error
+	}{
+		{"unsigned", false, false, 0, DNSSECUnsigned, nil},
+		{"secure", true, true, 1, DNSSECSecure, nil},
+		{"bogus", true, true, -1, DNSSECBogus, nil},
+		{"flaky upstream", true, true, -2, "", ErrServFail},
+		{"unvalidated", true, true, 0, DNSSECUnvalidated, nil},
+		{"ds without dnskey", true, false, -1, DNSSECDSWithoutDNSKEY, nil},
+		{"dnskey without ds", false, true, 0, DNSSECDNSKEYWithoutDS, nil},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resolver := NewResolver(Config{
+				Servers: []string{"192.0.2.1"},
+				Exchanger: exchangeFunc(func(msg *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+					question := msg.Question[0]
+					hdr := dns.RR_Header{Name: question.Name, Rrtype: question.Qtype, Class: dns.ClassINET, Ttl: 300}
+					if msg.IsEdns0() == nil || !msg.IsEdns0().Do() {
+						t.Fatalf("expected DO bit on %s query", dns.TypeToString[question.Qtype])
+					}
+
+					switch question.Qtype {
+					case dns.TypeDS:
+						if !msg.CheckingDisabled {
+							t.Fatalf("expected CD bit on DS query")
+						}
+						if tc.ds {
+							return answer(msg, &dns.DS{Hdr: hdr, KeyTag: 1, Algorithm: dns.ECDSAP256SHA256, DigestType: dns.SHA256, Digest: "00"}), 0, nil
+						}
+						return answer(msg), 0, nil
+					case dns.TypeDNSKEY:
+						if tc.dnskey {
+							return answer(msg, &dns.DNSKEY{Hdr: hdr, Flags: 257, Protocol: 3, Algorithm: dns.ECDSAP256SHA256, PublicKey: "AA=="}), 0, nil
+						}
+						return answer(msg), 0, nil
+					case dns.TypeSOA:
+						if tc.validated < -1 || (tc.validated < 0 && !msg.CheckingDisabled) {
+							return rcode(msg, dns.RcodeServerFailure), 0, nil
+						}
+						reply := answer(msg, &dns.SOA{Hdr: hdr, Ns: "ns1.example.com.", Mbox: "hostmaster.example.com.", Serial: 1})
+						reply.AuthenticatedData = tc.validated > 0
+						return reply, 0, nil
+					}
+					return answer(msg), 0, nil
+				}),
+			})
+
+			status, err := resolver.GetDNSSECStatus("example.com")
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("expected %v, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if status != tc.want {
+				t.Fatalf("expected %s, got %s", tc.want, status)
+			}
+		})
+	}
+}
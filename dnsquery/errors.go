@@ -0,0 +1,171 @@
+package dnsquery
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/miekg/dns"
+)
+
+// Sentinel errors matched by errors.Is against the errors returned from
+// lookups. ErrNXDomain and ErrNoData mean the record is really gone; the
+// others mean the answer is unknown and the previous value should be kept.
+var (
+	ErrNXDomain = errors.New("domain does not exist")
+	ErrNoData   = errors.New("no records of the requested type")
+	ErrServFail = errors.New("server failure")
+	ErrRefused  = errors.New("query refused")
+	ErrTimeout  = errors.New("query timed out")
+	// ErrNoConsensus means the resolvers queried with StrategyConsensus
+	// gave different answers and no answer reached the quorum.
+	ErrNoConsensus = errors.New("resolvers disagree")
+	// ErrTruncated means a reply was truncated and repeating the query
+	// over TCP did not produce a complete one.
+	ErrTruncated = errors.New("reply truncated")
+	// ErrInvalidName means the name could not be converted to the ASCII
+	// form queried on the wire, so it was never looked up.
+	ErrInvalidName = errors.New("invalid domain name")
+	// ErrPolicyUnavailable means the MTA-STS policy host could not be
+	// reached, so the policy it serves is unknown.
+	ErrPolicyUnavailable = errors.New("MTA-STS policy unavailable")
+)
+
+// IsNotFound reports whether err means the queried record does not exist,
+// as opposed to the lookup having failed.
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNXDomain) || errors.Is(err, ErrNoData)
+}
+
+// ErrorKind classifies why a lookup did not return the requested records.
+type ErrorKind int
+
+const (
+	// KindOther covers failures that fit none of the other kinds.
+	KindOther ErrorKind = iota
+	// KindNXDomain means the queried name does not exist.
+	KindNXDomain
+	// KindNoData means the name exists but has no records of the type.
+	KindNoData
+	// KindServFail means the upstream could not resolve the name.
+	KindServFail
+	// KindRefused means the upstream refused to answer.
+	KindRefused
+	// KindTimeout means no upstream replied in time.
+	KindTimeout
+	// KindNoConsensus means the upstreams disagreed and none of the
+	// answers reached the quorum.
+	KindNoConsensus
+	// KindTruncated means only a truncated reply could be obtained.
+	KindTruncated
+	// KindInvalidName means the queried name is not a valid domain name.
+	KindInvalidName
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case KindNXDomain:
+		return "NXDOMAIN"
+	case KindNoData:
+		return "NODATA"
+	case KindServFail:
+		return "SERVFAIL"
+	case KindRefused:
+		return "REFUSED"
+	case KindTimeout:
+		return "timeout"
+	case KindNoConsensus:
+		return "no consensus"
+	case KindTruncated:
+		return "truncated"
+	case KindInvalidName:
+		return "invalid name"
+	}
+	return "error"
+}
+
+// sentinel returns the exported error matching the kind, if any.
+func (k ErrorKind) sentinel() error {
+	switch k {
+	case KindNXDomain:
+		return ErrNXDomain
+	case KindNoData:
+		return ErrNoData
+	case KindServFail:
+		return ErrServFail
+	case KindRefused:
+		return ErrRefused
+	case KindTimeout:
+		return ErrTimeout
+	case KindNoConsensus:
+		return ErrNoConsensus
+	case KindTruncated:
+		return ErrTruncated
+	case KindInvalidName:
+		return ErrInvalidName
+	}
+	return nil
+}
+
+// LookupError is returned by every lookup that did not produce the
+// requested records.
+type LookupError struct {
+	Kind  ErrorKind
+	Name  string
+	Qtype uint16
+	// Err is the underlying transport error, if any.
+	Err error
+}
+
+func (e *LookupError) Error() string {
+	msg := fmt.Sprintf("%s lookup for %s: %s", dns.TypeToString[e.Qtype], e.Name, e.Kind)
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *LookupError) Unwrap() error {
+	return e.Err
+}
+
+// Is makes errors.Is match the sentinel error for the kind of failure.
+func (e *LookupError) Is(target error) bool {
+	return target != nil && target == e.Kind.sentinel()
+}
+
+// lookupError builds the LookupError for a failed exchange or for a reply
+// that carries no records of qtype.
+func lookupError(name string, qtype uint16, response *dns.Msg, err error) *LookupError {
+	lookupErr := &LookupError{Kind: KindOther, Name: name, Qtype: qtype, Err: err}
+	if err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			lookupErr.Kind = KindTimeout
+		}
+		if errors.Is(err, ErrNoConsensus) {
+			lookupErr.Kind = KindNoConsensus
+		}
+		if errors.Is(err, ErrTruncated) {
+			lookupErr.Kind = KindTruncated
+		}
+		if errors.Is(err, ErrInvalidName) {
+			lookupErr.Kind = KindInvalidName
+		}
+		return lookupErr
+	}
+
+	switch response.Rcode {
+	case dns.RcodeSuccess:
+		lookupErr.Kind = KindNoData
+	case dns.RcodeNameError:
+		lookupErr.Kind = KindNXDomain
+	case dns.RcodeServerFailure:
+		lookupErr.Kind = KindServFail
+	case dns.RcodeRefused:
+		lookupErr.Kind = KindRefused
+	default:
+		lookupErr.Err = fmt.Errorf("unexpected rcode %s", dns.RcodeToString[response.Rcode])
+	}
+	return lookupErr
+}
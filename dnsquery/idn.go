@@ -0,0 +1,80 @@
+package dnsquery
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/idna"
+)
+
+// NormalizeDomain validates a domain name as entered, possibly with Unicode
+// labels (U-labels), and returns it both in the ASCII form used on the wire
+// (A-labels, "xn--...") and in the Unicode form shown to people. Both are
+// lower case and without a trailing dot. Names that are not valid host
+// names under UTS #46, such as ones with underscores or mixed scripts that
+// break the bidi rule, are rejected with an error matching ErrInvalidName.
+func NormalizeDomain(name string) (ascii, unicode string, err error) {
+	trimmed := strings.TrimSuffix(strings.TrimSpace(name), ".")
+	ascii, err = idna.Lookup.ToASCII(trimmed)
+	if err != nil {
+		return "", "", fmt.Errorf("%w %q: %v", ErrInvalidName, name, err)
+	}
+	if !strings.Contains(ascii, ".") {
+		return "", "", fmt.Errorf("%w %q: not a fully qualified domain", ErrInvalidName, name)
+	}
+	if _, ok := dns.IsDomainName(ascii); !ok {
+		return "", "", fmt.Errorf("%w %q: too long or empty label", ErrInvalidName, name)
+	}
+	unicode, err = idna.Display.ToUnicode(ascii)
+	if err != nil {
+		return "", "", fmt.Errorf("%w %q: %v", ErrInvalidName, name, err)
+	}
+	return ascii, unicode, nil
+}
+
+// ToASCII converts the Unicode labels of name to A-labels so it can be
+// queried. ASCII labels are kept as they are, which leaves service labels
+// such as "_dmarc" alone.
+func ToASCII(name string) (string, error) {
+	labels := strings.Split(name, ".")
+	for i, label := range labels {
+		if isASCII(label) {
+			continue
+		}
+		converted, err := idna.Lookup.ToASCII(label)
+		if err != nil {
+			return "", fmt.Errorf("%w %q: %v", ErrInvalidName, name, err)
+		}
+		labels[i] = converted
+	}
+	return strings.Join(labels, "."), nil
+}
+
+// canonicalName returns name in lower case ASCII form without a trailing
+// dot, so names written with U-labels and A-labels compare equal.
+func canonicalName(name string) string {
+	if ascii, err := ToASCII(name); err == nil {
+		name = ascii
+	}
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
+
+// ToUnicode converts the A-labels of name to Unicode for display. Labels
+// that do not decode are kept as they are.
+func ToUnicode(name string) string {
+	unicode, err := idna.Display.ToUnicode(name)
+	if err != nil {
+		return name
+	}
+	return unicode
+}
+
+func isASCII(label string) bool {
+	for i := 0; i < len(label); i++ {
+		if label[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
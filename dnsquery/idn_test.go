@@ -0,0 +1,95 @@
+package dnsquery
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestNormalizeDomain(t *testing.T) {
+	cases := []struct {
+		name, ascii, unicode string
+	}{
+		{"example.com", "example.com", "example.com"},
+		{"Example.COM.", "example.com", "example.com"},
+		{"bücher.example", "xn--bcher-kva.example", "bücher.example"},
+		{"xn--bcher-kva.example", "xn--bcher-kva.example", "bücher.example"},
+		{"BÜCHER.example", "xn--bcher-kva.example", "bücher.example"},
+		{"münchen.de", "xn--mnchen-3ya.de", "münchen.de"},
+	}
+	for _, c := range cases {
+		ascii, unicode, err := NormalizeDomain(c.name)
+		if err != nil {
+			t.Fatalf("NormalizeDomain(%q): unexpected err: %v", c.name, err)
+		}
+		if ascii != c.ascii || unicode != c.unicode {
+			t.Fatalf("NormalizeDomain(%q) = %q, %q, want %q, %q", c.name, ascii, unicode, c.ascii, c.unicode)
+		}
+	}
+}
+
+func TestNormalizeDomain_RejectsInvalidNames(t *testing.T) {
+	for _, name := range []string{"", "localhost", "under_score.example", "-leading.example", "xn--zz.example", "a..example"} {
+		if _, _, err := NormalizeDomain(name); !errors.Is(err, ErrInvalidName) {
+			t.Fatalf("NormalizeDomain(%q): expected ErrInvalidName, got %v", name, err)
+		}
+	}
+}
+
+func TestToASCII_KeepsServiceLabels(t *testing.T) {
+	got, err := ToASCII("_dmarc.bücher.example")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if got != "_dmarc.xn--bcher-kva.example" {
+		t.Fatalf("unexpected name: %s", got)
+	}
+}
+
+func TestToUnicode(t *testing.T) {
+	if got := ToUnicode("xn--bcher-kva.example"); got != "bücher.example" {
+		t.Fatalf("unexpected name: %s", got)
+	}
+	if got := ToUnicode("example.com"); got != "example.com" {
+		t.Fatalf("unexpected name: %s", got)
+	}
+}
+
+func TestQuery_SendsALabels(t *testing.T) {
+	var queried string
+	resolver := NewResolver(Config{
+		Servers: []string{"192.0.2.1"},
+		Exchanger: exchangeFunc(func(msg *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+			queried = msg.Question[0].Name
+			return answer(msg, nsRecord(msg)), 0, nil
+		}),
+	})
+
+	if _, err := resolver.Query("bücher.example", dns.TypeNS); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if queried != "xn--bcher-kva.example." {
+		t.Fatalf("expected the A-label to be queried, got %s", queried)
+	}
+}
+
+func TestQuery_InvalidNameIsNotNotFound(t *testing.T) {
+	resolver := NewResolver(Config{
+		Servers: []string{"192.0.2.1"},
+		Exchanger: exchangeFunc(func(msg *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+			t.Fatalf("invalid name was sent upstream: %s", msg.Question[0].Name)
+			return nil, 0, nil
+		}),
+	})
+
+	// A zero width joiner between Latin letters is not allowed by IDNA
+	_, err := resolver.Query("a\u200db.example", dns.TypeA)
+	if !errors.Is(err, ErrInvalidName) {
+		t.Fatalf("expected ErrInvalidName, got %v", err)
+	}
+	if IsNotFound(err) {
+		t.Fatalf("an invalid name must not look like a deleted record")
+	}
+}
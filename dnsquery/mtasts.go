@@ -0,0 +1,211 @@
+package dnsquery
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// mtaSTSPolicyMaxSize bounds the policy body read from the policy host.
+const mtaSTSPolicyMaxSize = 64 * 1024
+
+// MTASTSPolicy is the MTA-STS state of a domain (RFC 8461): the id from the
+// _mta-sts TXT record and the policy fetched over HTTPS.
+type MTASTSPolicy struct {
+	ID      string
+	Version string
+	Mode    string
+	MX      []string
+	MaxAge  int
+	// Issues lists problems with the record or the policy, including a
+	// policy that could not be fetched.
+	Issues []string
+}
+
+func (p *MTASTSPolicy) addIssue(format string, args ...interface{}) {
+	p.Issues = append(p.Issues, fmt.Sprintf(format, args...))
+}
+
+// Matches reports whether host is covered by one of the mx patterns of the
+// policy. A "*." pattern matches exactly one extra label.
+func (p *MTASTSPolicy) Matches(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	for _, pattern := range p.MX {
+		pattern = strings.TrimSuffix(strings.ToLower(pattern), ".")
+		if strings.HasPrefix(pattern, "*.") {
+			label, rest, found := strings.Cut(host, ".")
+			if found && label != "" && rest == pattern[2:] {
+				return true
+			}
+			continue
+		}
+		if host == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckMX lists the MX hosts not covered by the policy. Policies in "none"
+// mode are not checked.
+func (p *MTASTSPolicy) CheckMX(records []MXRecord) []string {
+	var issues []string
+	if p.Mode == "none" || p.Mode == "" {
+		return issues
+	}
+	for _, record := range records {
+		if !p.Matches(record.Host) {
+			issues = append(issues, fmt.Sprintf("MX host %s is not covered by the MTA-STS policy", record.Host))
+		}
+	}
+	return issues
+}
+
+// ParseMTASTSPolicy parses the body of an mta-sts.txt policy file.
+func ParseMTASTSPolicy(body string) (*MTASTSPolicy, error) {
+	policy := &MTASTSPolicy{}
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			return nil, fmt.Errorf("malformed MTA-STS policy line %q", line)
+		}
+		value = strings.TrimSpace(value)
+
+		switch strings.TrimSpace(key) {
+		case "version":
+			policy.Version = value
+		case "mode":
+			policy.Mode = value
+		case "mx":
+			policy.MX = append(policy.MX, value)
+		case "max_age":
+			maxAge, err := strconv.Atoi(value)
+			if err != nil || maxAge < 0 {
+				return nil, fmt.Errorf("invalid MTA-STS max_age %q", value)
+			}
+			policy.MaxAge = maxAge
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if policy.Version != "STSv1" {
+		return nil, fmt.Errorf("unsupported MTA-STS policy version %q", policy.Version)
+	}
+	switch policy.Mode {
+	case "enforce", "testing":
+		if len(policy.MX) == 0 {
+			return nil, fmt.Errorf("MTA-STS policy in %s mode lists no mx", policy.Mode)
+		}
+	case "none":
+	default:
+		return nil, fmt.Errorf("invalid MTA-STS mode %q", policy.Mode)
+	}
+	return policy, nil
+}
+
+// GetMTASTSPolicy fetches the MTA-STS policy of domain using the default
+// resolver.
+func GetMTASTSPolicy(domain string) (*MTASTSPolicy, error) {
+	return defaultResolver.GetMTASTSPolicy(domain)
+}
+
+// GetMTASTSPolicy looks up the _mta-sts TXT record of domain and fetches the
+// policy from https://mta-sts.<domain>/.well-known/mta-sts.txt. Errors are
+// returned for the TXT lookup and, wrapping ErrPolicyUnavailable, when the
+// policy host cannot be reached; a policy host that answers with an error
+// status or an invalid policy is reported in Issues.
+func (r *Resolver) GetMTASTSPolicy(domain string) (*MTASTSPolicy, error) {
+	txtRecords, err := r.GetTXTRecords("_mta-sts." + domain)
+	if err != nil {
+		return nil, err
+	}
+
+	var stsRecords []string
+	for _, txt := range txtRecords {
+		if strings.HasPrefix(txt, "v=STSv1") {
+			stsRecords = append(stsRecords, txt)
+		}
+	}
+	if len(stsRecords) == 0 {
+		return nil, fmt.Errorf("no MTA-STS record found for %s: %w", domain, ErrNoData)
+	}
+
+	result := &MTASTSPolicy{ID: parseTags(stsRecords[0])["id"]}
+	if len(stsRecords) > 1 {
+		result.addIssue("%d MTA-STS records published, senders will ignore all of them", len(stsRecords))
+	}
+	if result.ID == "" {
+		result.addIssue("MTA-STS record has no id")
+	}
+
+	policy, err := r.fetchMTASTSPolicy(domain)
+	if errors.Is(err, ErrPolicyUnavailable) {
+		return nil, err
+	}
+	if err != nil {
+		result.addIssue("MTA-STS policy unavailable: %v", err)
+		return result, nil
+	}
+	policy.ID = result.ID
+	policy.Issues = result.Issues
+	return policy, nil
+}
+
+// fetchMTASTSPolicy downloads and parses the policy file of domain.
+func (r *Resolver) fetchMTASTSPolicy(domain string) (*MTASTSPolicy, error) {
+	host, err := ToASCII("mta-sts." + domain)
+	if err != nil {
+		return nil, err
+	}
+	request, err := http.NewRequestWithContext(r.ctx, http.MethodGet, "https://"+host+"/.well-known/mta-sts.txt", nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := r.http.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrPolicyUnavailable, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("policy host answered %s", response.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(response.Body, mtaSTSPolicyMaxSize))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrPolicyUnavailable, err)
+	}
+	return ParseMTASTSPolicy(string(body))
+}
+
+// GetTLSRPTRecord fetches the SMTP TLS reporting record of a domain using
+// the default resolver.
+func GetTLSRPTRecord(domain string) (string, error) {
+	return defaultResolver.GetTLSRPTRecord(domain)
+}
+
+// GetTLSRPTRecord fetches the SMTP TLS reporting record (RFC 8460) of a
+// domain from _smtp._tls.<domain>.
+func (r *Resolver) GetTLSRPTRecord(domain string) (string, error) {
+	records, err := r.GetTXTRecords("_smtp._tls." + domain)
+	if err != nil {
+		return "", err
+	}
+
+	for _, record := range records {
+		if strings.HasPrefix(record, "v=TLSRPTv1") {
+			return record, nil
+		}
+	}
+	return "", fmt.Errorf("no TLS-RPT record found for %s: %w", domain, ErrNoData)
+}
@@ -0,0 +1,113 @@
+package dnsquery
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// policyServer starts a local HTTPS stand-in for every mta-sts host and
+// returns a client that sends all requests to it.
+func policyServer(t *testing.T, handler http.HandlerFunc) *http.Client {
+	t.Helper()
+	server := httptest.NewTLSServer(handler)
+	t.Cleanup(server.Close)
+
+	client := server.Client()
+	client.Transport.(*http.Transport).DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+	}
+	return client
+}
+
+func TestGetMTASTSPolicy_FetchesPolicy(t *testing.T) {
+	client := policyServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Host != "mta-sts.example.com" || r.URL.Path != "/.well-known/mta-sts.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("version: STSv1\r\nmode: enforce\r\nmx: mail.example.com\r\nmx: *.mx.example.net\r\nmax_age: 604800\r\n"))
+	})
+
+	resolver := zoneResolver(t, `_mta-sts.example.com. 300 IN TXT "v=STSv1; id=20240101T000000"`)
+	resolver.http = client
+
+	policy, err := resolver.GetMTASTSPolicy("example.com")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(policy.Issues) != 0 {
+		t.Fatalf("unexpected issues: %v", policy.Issues)
+	}
+	if policy.ID != "20240101T000000" || policy.Mode != "enforce" || policy.MaxAge != 604800 || len(policy.MX) != 2 {
+		t.Fatalf("unexpected policy: %+v", policy)
+	}
+
+	issues := policy.CheckMX([]MXRecord{
+		{Preference: 10, Host: "mail.example.com."},
+		{Preference: 20, Host: "a.mx.example.net."},
+		{Preference: 30, Host: "b.a.mx.example.net."},
+		{Preference: 40, Host: "backup.example.org."},
+	})
+	if len(issues) != 2 || !strings.Contains(issues[0], "b.a.mx.example.net.") || !strings.Contains(issues[1], "backup.example.org.") {
+		t.Fatalf("unexpected mx issues: %v", issues)
+	}
+}
+
+func TestGetMTASTSPolicy_PolicyUnavailable(t *testing.T) {
+	client := policyServer(t, func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+
+	resolver := zoneResolver(t, `_mta-sts.example.com. 300 IN TXT "v=STSv1; id=1"`)
+	resolver.http = client
+
+	policy, err := resolver.GetMTASTSPolicy("example.com")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if policy.ID != "1" || len(policy.Issues) != 1 || !strings.Contains(policy.Issues[0], "404") {
+		t.Fatalf("expected unavailable policy issue, got %+v", policy)
+	}
+}
+
+func TestGetMTASTSPolicy_PolicyHostUnreachable(t *testing.T) {
+	resolver := zoneResolver(t, `_mta-sts.example.com. 300 IN TXT "v=STSv1; id=1"`)
+	resolver.http = &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+			return nil, errors.New("connection refused")
+		},
+	}}
+
+	if _, err := resolver.GetMTASTSPolicy("example.com"); !errors.Is(err, ErrPolicyUnavailable) {
+		t.Fatalf("expected ErrPolicyUnavailable, got %v", err)
+	}
+}
+
+func TestParseMTASTSPolicy_Invalid(t *testing.T) {
+	for _, body := range []string{
+		"version: STSv2\nmode: enforce\nmx: mail.example.com\nmax_age: 1\n",
+		"version: STSv1\nmode: strict\nmx: mail.example.com\nmax_age: 1\n",
+		"version: STSv1\nmode: enforce\nmax_age: 1\n",
+	} {
+		if _, err := ParseMTASTSPolicy(body); err == nil {
+			t.Fatalf("expected error for policy %q", body)
+		}
+	}
+}
+
+func TestGetTLSRPTRecord(t *testing.T) {
+	resolver := zoneResolver(t, `_smtp._tls.example.com. 300 IN TXT "v=TLSRPTv1; rua=mailto:tlsrpt@example.com"`)
+
+	record, err := resolver.GetTLSRPTRecord("example.com")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if record != "v=TLSRPTv1; rua=mailto:tlsrpt@example.com" {
+		t.Fatalf("unexpected record: %s", record)
+	}
+}
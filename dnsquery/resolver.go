@@ -0,0 +1,419 @@
+package dnsquery
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Strategy controls the order in which the configured servers are tried.
+type Strategy int
+
+const (
+	// StrategyOrdered always starts with the first configured server and
+	// falls back to the next one on failure.
+	StrategyOrdered Strategy = iota
+	// StrategyRoundRobin rotates the starting server on every query.
+	StrategyRoundRobin
+	// StrategyConsensus queries every server in parallel and only accepts
+	// an answer returned by a quorum of them.
+	StrategyConsensus
+)
+
+// ParseStrategy converts a configuration value ("ordered", "round-robin" or
+// "consensus") into a Strategy. An empty value selects StrategyOrdered.
+func ParseStrategy(value string) (Strategy, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "ordered":
+		return StrategyOrdered, nil
+	case "round-robin", "roundrobin":
+		return StrategyRoundRobin, nil
+	case "consensus":
+		return StrategyConsensus, nil
+	}
+	return StrategyOrdered, fmt.Errorf("unknown resolver strategy %q", value)
+}
+
+// Exchanger sends a DNS message to a server and returns the reply, giving
+// up when ctx is done. *dns.Client satisfies it; tests can supply their own
+// implementation.
+type Exchanger interface {
+	ExchangeContext(ctx context.Context, msg *dns.Msg, address string) (*dns.Msg, time.Duration, error)
+}
+
+// Transferer starts a zone transfer and streams the reply. A reply with an
+// rcode other than NOERROR is reported as a TransferRcodeError; tests can
+// supply their own implementation.
+type Transferer interface {
+	In(msg *dns.Msg, address string) (chan *dns.Envelope, error)
+}
+
+// Config describes the upstream resolvers used for lookups.
+type Config struct {
+	// Servers is the list of upstream resolvers as host or host:port.
+	// Port 53 is assumed when none is given.
+	// With TransportTLS a server may be followed by "#name" to verify its
+	// certificate against name rather than host; with TransportHTTPS
+	// servers are URLs.
+	Servers []string
+	// Transport selects plain DNS, DNS over TLS or DNS over HTTPS for the
+	// upstream servers. Queries sent straight to authoritative nameservers
+	// use plain DNS on port 53: over UDP with TransportUDP, over TCP
+	// otherwise, as networks that require an encrypted transport usually
+	// block outgoing UDP.
+	Transport Transport
+	// TLSConfig holds the TLS settings for DNS over TLS and DNS over HTTPS,
+	// such as RootCAs. ServerName is filled in per server.
+	TLSConfig *tls.Config
+	// Strategy decides which server is tried first, or whether all of
+	// them are asked at once.
+	Strategy Strategy
+	// Quorum is the number of servers that must return the same answer
+	// with StrategyConsensus. Zero means a majority of Servers. With half
+	// of Servers or less, the answer with the most votes wins.
+	Quorum int
+	// Timeout bounds every single exchange with an upstream.
+	Timeout time.Duration
+	// Retries is the number of extra passes over Servers after the first
+	// one failed.
+	Retries int
+	// UDPSize is the EDNS0 buffer size advertised in queries. Zero selects
+	// 1232, which avoids IP fragmentation on common paths.
+	UDPSize uint16
+	// CacheSize is the number of replies kept until their TTL expires, so
+	// names looked up repeatedly are only sent upstream once. Zero
+	// disables the cache.
+	CacheSize int
+	// Exchanger overrides the client used to talk to the servers and to
+	// authoritative nameservers. When nil a client for Transport with
+	// Timeout is used.
+	Exchanger Exchanger
+	// TCPExchanger repeats queries whose reply came back truncated. When
+	// nil a TCP dns.Client with Timeout is used, or Exchanger when that
+	// is set.
+	TCPExchanger Exchanger
+	// HTTPClient fetches policies published over HTTPS, such as MTA-STS.
+	// When nil a client with Timeout that does not follow redirects is used.
+	HTTPClient *http.Client
+	// Transferer overrides the client used for zone transfers. When nil a
+	// client with Timeout that opens a new TCP connection per transfer is
+	// used.
+	Transferer Transferer
+}
+
+// DefaultConfig returns the configuration used by the package level
+// functions: Cloudflare's public resolver with a five second timeout.
+func DefaultConfig() Config {
+	return Config{
+		Servers:   []string{"1.1.1.1:53"},
+		Strategy:  StrategyOrdered,
+		Timeout:   5 * time.Second,
+		Retries:   1,
+		CacheSize: defaultCacheSize,
+	}
+}
+
+// defaultUDPSize is the EDNS0 buffer size advertised unless configured
+// otherwise, as recommended by DNS Flag Day 2020.
+const defaultUDPSize = 1232
+
+// Resolver sends queries to a configured set of upstream servers. Lookups
+// stop early when the context set with WithContext is done.
+type Resolver struct {
+	servers   []string
+	strategy  Strategy
+	quorum    int
+	retries   int
+	udpSize   uint16
+	exchanger Exchanger
+	direct    Exchanger
+	tcp       Exchanger
+	http      *http.Client
+	transfer  Transferer
+	cache     *cache
+	ctx       context.Context
+	// state is shared with the copies made by WithContext.
+	state *resolverState
+}
+
+// resolverState is the mutable state of a Resolver.
+type resolverState struct {
+	next uint32
+
+	mu            sync.Mutex
+	disagreements []Disagreement
+	tcpFallbacks  []string
+}
+
+// NewResolver builds a Resolver from config. Missing values are taken from
+// DefaultConfig.
+func NewResolver(config Config) *Resolver {
+	defaults := DefaultConfig()
+	if len(config.Servers) == 0 {
+		config.Servers = config.Transport.defaultServers()
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaults.Timeout
+	}
+	if config.Retries < 0 {
+		config.Retries = 0
+	}
+	if config.UDPSize == 0 {
+		config.UDPSize = defaultUDPSize
+	}
+	if config.Quorum <= 0 {
+		config.Quorum = len(config.Servers)/2 + 1
+	}
+	if config.Quorum > len(config.Servers) {
+		config.Quorum = len(config.Servers)
+	}
+
+	servers := make([]string, 0, len(config.Servers))
+	names := map[string]string{}
+	for _, server := range config.Servers {
+		if config.Transport == TransportHTTPS {
+			servers = append(servers, strings.TrimSpace(server))
+			continue
+		}
+		server, name, _ := strings.Cut(server, "#")
+		address := hostPort(server, config.Transport.defaultPort())
+		if name != "" {
+			names[address] = name
+		}
+		servers = append(servers, address)
+	}
+
+	// Authoritative nameservers are queried directly, over TCP unless the
+	// upstream servers are reached over UDP
+	exchanger, direct := config.Exchanger, config.Exchanger
+	if direct == nil {
+		direct = &dns.Client{Timeout: config.Timeout}
+		if config.Transport != TransportUDP {
+			direct = &dns.Client{Net: "tcp", Timeout: config.Timeout}
+		}
+	}
+	if exchanger == nil {
+		switch config.Transport {
+		case TransportTCP:
+			exchanger = &dns.Client{Net: "tcp", Timeout: config.Timeout}
+		case TransportTLS:
+			exchanger = &tlsExchanger{timeout: config.Timeout, config: config.TLSConfig, names: names}
+		case TransportHTTPS:
+			exchanger = &httpsExchanger{client: &http.Client{
+				Timeout:   config.Timeout,
+				Transport: &http.Transport{TLSClientConfig: config.TLSConfig, ForceAttemptHTTP2: true},
+			}}
+		default:
+			exchanger = direct
+		}
+	}
+
+	tcp := config.TCPExchanger
+	switch {
+	case tcp != nil:
+	case config.Exchanger != nil:
+		tcp = config.Exchanger
+	default:
+		tcp = &dns.Client{Net: "tcp", Timeout: config.Timeout}
+	}
+
+	httpClient := config.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{
+			Timeout: config.Timeout,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+	}
+
+	transferer := config.Transferer
+	if transferer == nil {
+		transferer = newZoneTransferer(config.Timeout)
+	}
+
+	return &Resolver{
+		servers:   servers,
+		strategy:  config.Strategy,
+		quorum:    config.Quorum,
+		retries:   config.Retries,
+		udpSize:   config.UDPSize,
+		exchanger: exchanger,
+		direct:    direct,
+		tcp:       tcp,
+		http:      httpClient,
+		transfer:  transferer,
+		cache:     newCache(config.CacheSize),
+		ctx:       context.Background(),
+		state:     &resolverState{},
+	}
+}
+
+// serverAddress appends the default DNS port to server when it has none.
+func serverAddress(server string) string {
+	return hostPort(server, "53")
+}
+
+// hostPort appends port to server when it has none.
+func hostPort(server, port string) string {
+	server = strings.TrimSpace(server)
+	if _, _, err := net.SplitHostPort(server); err == nil {
+		return server
+	}
+	return net.JoinHostPort(strings.Trim(server, "[]"), port)
+}
+
+// WithContext returns a copy of the resolver whose lookups give up when ctx
+// is done. The copy shares the server rotation and the recorded
+// disagreements and TCP fallbacks with r.
+func (r *Resolver) WithContext(ctx context.Context) *Resolver {
+	copy := *r
+	copy.ctx = ctx
+	return &copy
+}
+
+// Servers returns the upstream addresses in the order the next query will
+// try them.
+func (r *Resolver) Servers() []string {
+	ordered := make([]string, len(r.servers))
+	start := 0
+	if r.strategy == StrategyRoundRobin {
+		start = int(atomic.AddUint32(&r.state.next, 1)-1) % len(r.servers)
+	}
+	for i := range r.servers {
+		ordered[i] = r.servers[(start+i)%len(r.servers)]
+	}
+	return ordered
+}
+
+// Query performs a recursive query for domain and qtype and returns the
+// answer section. Every failure, including an answer without records of
+// qtype, is reported as a *LookupError.
+func (r *Resolver) Query(domain string, qtype uint16) ([]dns.RR, error) {
+	msg, err := r.newQuery(domain, qtype)
+	if err != nil {
+		return nil, lookupError(domain, qtype, nil, err)
+	}
+	response, err := r.exchange(msg)
+	if err != nil {
+		return nil, lookupError(domain, qtype, nil, err)
+	}
+
+	if response.Rcode != dns.RcodeSuccess || !hasType(response.Answer, qtype) {
+		return nil, lookupError(domain, qtype, response, nil)
+	}
+	return response.Answer, nil
+}
+
+// newQuery builds a recursive query for domain and qtype advertising the
+// configured EDNS0 buffer size. Unicode labels are sent as A-labels.
+func (r *Resolver) newQuery(domain string, qtype uint16) (*dns.Msg, error) {
+	name, err := ToASCII(domain)
+	if err != nil {
+		return nil, err
+	}
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(name), qtype)
+	msg.RecursionDesired = true
+	msg.AuthenticatedData = true // Set the AD bit
+	msg.SetEdns0(r.udpSize, false)
+	return msg, nil
+}
+
+// exchange answers msg from the cache, or asks the upstream servers and
+// caches their reply.
+func (r *Resolver) exchange(msg *dns.Msg) (*dns.Msg, error) {
+	if response := r.cache.get(msg); response != nil {
+		return response, nil
+	}
+	response, err := r.exchangeUpstream(msg)
+	if err == nil {
+		r.cache.put(msg, response)
+	}
+	return response, err
+}
+
+// exchangeUpstream sends msg to the upstream servers until one of them gives
+// a usable reply. SERVFAIL and REFUSED are treated like transport errors so
+// the next server gets a chance to answer.
+func (r *Resolver) exchangeUpstream(msg *dns.Msg) (*dns.Msg, error) {
+	if r.strategy == StrategyConsensus {
+		return r.exchangeConsensus(msg)
+	}
+
+	var (
+		response *dns.Msg
+		err      error
+	)
+	for attempt := 0; attempt <= r.retries; attempt++ {
+		for _, server := range r.Servers() {
+			if ctxErr := r.ctx.Err(); ctxErr != nil {
+				return nil, ctxErr
+			}
+			response, err = r.exchangeServer(r.exchanger, msg, server)
+			if err != nil {
+				continue
+			}
+			if response.Rcode == dns.RcodeServerFailure || response.Rcode == dns.RcodeRefused {
+				continue
+			}
+			return response, nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// exchangeServer sends msg to a single server and repeats it over TCP when
+// the reply was truncated, so a partial answer is never returned. Replies
+// that needed TCP are recorded for TCPFallbacks.
+func (r *Resolver) exchangeServer(exchanger Exchanger, msg *dns.Msg, address string) (*dns.Msg, error) {
+	response, _, err := exchanger.ExchangeContext(r.ctx, msg, address)
+	if err != nil || !response.Truncated {
+		return response, err
+	}
+
+	response, _, err = r.tcp.ExchangeContext(r.ctx, msg, address)
+	if err != nil {
+		return nil, fmt.Errorf("%w, TCP retry to %s failed: %v", ErrTruncated, address, err)
+	}
+	if response.Truncated {
+		return nil, fmt.Errorf("%w over TCP by %s", ErrTruncated, address)
+	}
+
+	question := msg.Question[0]
+	r.state.mu.Lock()
+	r.state.tcpFallbacks = append(r.state.tcpFallbacks, dns.TypeToString[question.Qtype]+" "+question.Name)
+	r.state.mu.Unlock()
+	return response, nil
+}
+
+// TCPFallbacks returns the queries answered over TCP after a truncated UDP
+// reply since the previous call, as "TXT example.com.", and forgets them.
+func (r *Resolver) TCPFallbacks() []string {
+	r.state.mu.Lock()
+	defer r.state.mu.Unlock()
+	fallbacks := r.state.tcpFallbacks
+	r.state.tcpFallbacks = nil
+	return fallbacks
+}
+
+// hasType reports whether records contain at least one record of qtype.
+func hasType(records []dns.RR, qtype uint16) bool {
+	for _, record := range records {
+		if record.Header().Rrtype == qtype {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,293 @@
+package dnsquery
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// exchangeFunc adapts a function to the Exchanger interface.
+type exchangeFunc func(msg *dns.Msg, address string) (*dns.Msg, time.Duration, error)
+
+func (f exchangeFunc) ExchangeContext(ctx context.Context, msg *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+	return f(msg, address)
+}
+
+// answer builds a successful reply to msg carrying records.
+func answer(msg *dns.Msg, records ...dns.RR) *dns.Msg {
+	reply := new(dns.Msg)
+	reply.SetReply(msg)
+	reply.Answer = records
+	return reply
+}
+
+// rcode builds a reply to msg with the given response code.
+func rcode(msg *dns.Msg, code int) *dns.Msg {
+	reply := new(dns.Msg)
+	reply.SetRcode(msg, code)
+	return reply
+}
+
+// nsRecord builds an NS record answering msg.
+func nsRecord(msg *dns.Msg) dns.RR {
+	return &dns.NS{Hdr: dns.RR_Header{Name: msg.Question[0].Name, Rrtype: dns.TypeNS, Class: dns.ClassINET, Ttl: 300}, Ns: "ns1.example.com."}
+}
+
+func TestNewResolver_AddsDefaultPort(t *testing.T) {
+	resolver := NewResolver(Config{Servers: []string{"10.0.0.1", "10.0.0.2:5353", "2001:db8::1"}})
+
+	want := []string{"10.0.0.1:53", "10.0.0.2:5353", "[2001:db8::1]:53"}
+	if got := resolver.Servers(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected servers: %v", got)
+	}
+}
+
+func TestResolver_RoundRobinRotatesStartingServer(t *testing.T) {
+	var used []string
+	resolver := NewResolver(Config{
+		Servers:  []string{"10.0.0.1", "10.0.0.2"},
+		Strategy: StrategyRoundRobin,
+		Exchanger: exchangeFunc(func(msg *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+			used = append(used, address)
+			return answer(msg, nsRecord(msg)), 0, nil
+		}),
+	})
+
+	for i := 0; i < 3; i++ {
+		if _, err := resolver.Query("example.com", dns.TypeNS); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+	}
+
+	want := []string{"10.0.0.1:53", "10.0.0.2:53", "10.0.0.1:53"}
+	if !reflect.DeepEqual(used, want) {
+		t.Fatalf("unexpected server order: %v", used)
+	}
+}
+
+func TestResolver_FallsBackOnErrorAndServfail(t *testing.T) {
+	var used []string
+	resolver := NewResolver(Config{
+		Servers: []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"},
+		Exchanger: exchangeFunc(func(msg *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+			used = append(used, address)
+			switch address {
+			case "10.0.0.1:53":
+				return nil, 0, errors.New("i/o timeout")
+			case "10.0.0.2:53":
+				return rcode(msg, dns.RcodeServerFailure), 0, nil
+			}
+			return answer(msg, nsRecord(msg)), 0, nil
+		}),
+	})
+
+	if _, err := resolver.Query("example.com", dns.TypeNS); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(used) != 3 {
+		t.Fatalf("expected all three servers to be tried, got %v", used)
+	}
+}
+
+func TestResolver_RetriesBeforeGivingUp(t *testing.T) {
+	attempts := 0
+	resolver := NewResolver(Config{
+		Servers: []string{"10.0.0.1"},
+		Retries: 2,
+		Exchanger: exchangeFunc(func(msg *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+			attempts++
+			return nil, 0, errors.New("i/o timeout")
+		}),
+	})
+
+	if _, err := resolver.Query("example.com", dns.TypeNS); err == nil {
+		t.Fatalf("expected error when every attempt fails")
+	}
+	if attempts != 3 {
+		t.Fatalf("expected 3 attempts, got %d", attempts)
+	}
+}
+
+func TestParseStrategy(t *testing.T) {
+	if s, err := ParseStrategy("round-robin"); err != nil || s != StrategyRoundRobin {
+		t.Fatalf("unexpected result: %v %v", s, err)
+	}
+	if s, err := ParseStrategy(""); err != nil || s != StrategyOrdered {
+		t.Fatalf("unexpected result: %v %v", s, err)
+	}
+	if _, err := ParseStrategy("random"); err == nil {
+		t.Fatalf("expected error for unknown strategy")
+	}
+}
+
+// timeoutError is a net.Error that reports a timeout.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// zoneResolver returns a Resolver answering from records given in zone file
+// syntax, as the authoritative nameserver of every name would. Names without
+// records get NXDOMAIN, names without records of the queried type get an
+// empty answer.
+func zoneResolver(t *testing.T, records ...string) *Resolver {
+	t.Helper()
+	zone := parseZone(t, records)
+	return NewResolver(Config{
+		Servers: []string{"192.0.2.1"},
+		Exchanger: exchangeFunc(func(msg *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+			return zoneReply(msg, zone), 0, nil
+		}),
+	})
+}
+
+// zonesResolver returns a Resolver built from config whose servers, and the
+// nameservers it queries directly, each answer from their own zone, keyed
+// by host, like zoneResolver does. Hosts without a zone time out.
+func zonesResolver(t *testing.T, config Config, zones map[string][]string) *Resolver {
+	t.Helper()
+	parsed := map[string]map[string][]dns.RR{}
+	for host, records := range zones {
+		parsed[host] = parseZone(t, records)
+	}
+	config.Exchanger = exchangeFunc(func(msg *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+		host, _, _ := net.SplitHostPort(address)
+		zone, ok := parsed[host]
+		if !ok {
+			return nil, 0, &net.OpError{Op: "read", Err: timeoutError{}}
+		}
+		return zoneReply(msg, zone), 0, nil
+	})
+	return NewResolver(config)
+}
+
+// parseZone indexes records given in zone file syntax by lower case owner.
+func parseZone(t *testing.T, records []string) map[string][]dns.RR {
+	t.Helper()
+	zone := map[string][]dns.RR{}
+	for _, record := range records {
+		rr, err := dns.NewRR(record)
+		if err != nil {
+			t.Fatalf("invalid test record %q: %v", record, err)
+		}
+		name := strings.ToLower(rr.Header().Name)
+		zone[name] = append(zone[name], rr)
+	}
+	return zone
+}
+
+// zoneReply answers msg authoritatively from zone.
+func zoneReply(msg *dns.Msg, zone map[string][]dns.RR) *dns.Msg {
+	question := msg.Question[0]
+	rrs, ok := zone[strings.ToLower(question.Name)]
+	if !ok {
+		reply := rcode(msg, dns.RcodeNameError)
+		reply.Authoritative = true
+		return reply
+	}
+	var matching []dns.RR
+	for _, rr := range rrs {
+		if rr.Header().Rrtype == question.Qtype {
+			matching = append(matching, rr)
+		}
+	}
+	reply := answer(msg, matching...)
+	reply.Authoritative = true
+	return reply
+}
+
+// lameServer makes host answer the queries sent to it directly without the
+// AA bit, like a nameserver that no longer serves the zone.
+func lameServer(resolver *Resolver, host string) {
+	direct := resolver.direct
+	resolver.direct = exchangeFunc(func(msg *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+		reply, rtt, err := direct.ExchangeContext(resolver.ctx, msg, address)
+		if addressHost, _, _ := net.SplitHostPort(address); err == nil && addressHost == host {
+			reply.Authoritative = false
+		}
+		return reply, rtt, err
+	})
+}
+
+// queriedNames records the name of every query resolver sends upstream.
+func queriedNames(resolver *Resolver) *[]string {
+	var names []string
+	exchanger := resolver.exchanger
+	resolver.exchanger = exchangeFunc(func(msg *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+		names = append(names, msg.Question[0].Name)
+		return exchanger.ExchangeContext(resolver.ctx, msg, address)
+	})
+	return &names
+}
+
+func TestQuery_AdvertisesEDNS0BufferSize(t *testing.T) {
+	var size uint16
+	resolver := NewResolver(Config{
+		Servers: []string{"192.0.2.1"},
+		UDPSize: 4096,
+		Exchanger: exchangeFunc(func(msg *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+			if opt := msg.IsEdns0(); opt != nil {
+				size = opt.UDPSize()
+			}
+			return answer(msg, nsRecord(msg)), 0, nil
+		}),
+	})
+
+	if _, err := resolver.Query("example.com", dns.TypeNS); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if size != 4096 {
+		t.Fatalf("expected EDNS0 buffer size 4096, got %d", size)
+	}
+}
+
+func TestQuery_RetriesTruncatedReplyOverTCP(t *testing.T) {
+	resolver := NewResolver(Config{
+		Servers: []string{"192.0.2.1"},
+		Exchanger: exchangeFunc(func(msg *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+			reply := answer(msg)
+			reply.Truncated = true
+			return reply, 0, nil
+		}),
+		TCPExchanger: exchangeFunc(func(msg *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+			return answer(msg, nsRecord(msg)), 0, nil
+		}),
+	})
+
+	records, err := resolver.Query("example.com", dns.TypeNS)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("expected the TCP answer, got %v", records)
+	}
+	if fallbacks := resolver.TCPFallbacks(); !reflect.DeepEqual(fallbacks, []string{"NS example.com."}) {
+		t.Fatalf("expected the fallback to be recorded, got %v", fallbacks)
+	}
+}
+
+func TestQuery_TruncatedReplyIsNotNotFound(t *testing.T) {
+	resolver := NewResolver(Config{
+		Servers: []string{"192.0.2.1"},
+		Exchanger: exchangeFunc(func(msg *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+			reply := answer(msg)
+			reply.Truncated = true
+			return reply, 0, nil
+		}),
+		TCPExchanger: exchangeFunc(func(msg *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+			return nil, 0, errors.New("connection refused")
+		}),
+	})
+
+	_, err := resolver.Query("example.com", dns.TypeTXT)
+	if !errors.Is(err, ErrTruncated) || IsNotFound(err) {
+		t.Fatalf("expected ErrTruncated, got %v", err)
+	}
+}
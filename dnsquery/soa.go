@@ -0,0 +1,49 @@
+package dnsquery
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// SOARecord is the start of authority of a zone.
+type SOARecord struct {
+	MName   string
+	RName   string
+	Serial  uint32
+	Refresh uint32
+	Retry   uint32
+	Expire  uint32
+	Minimum uint32
+}
+
+// GetSOARecord fetches the SOA record of a domain using the default resolver.
+func GetSOARecord(domain string) (*SOARecord, error) {
+	return defaultResolver.GetSOARecord(domain)
+}
+
+// GetSOARecord fetches the SOA record at the apex of domain. Names below a
+// zone cut get the parent's SOA in the authority section, which is not an
+// answer, so they are reported as having no SOA record.
+func (r *Resolver) GetSOARecord(domain string) (*SOARecord, error) {
+	records, err := r.Query(domain, dns.TypeSOA)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, record := range records {
+		if soa, ok := record.(*dns.SOA); ok {
+			return &SOARecord{
+				MName:   strings.ToLower(soa.Ns),
+				RName:   strings.ToLower(soa.Mbox),
+				Serial:  soa.Serial,
+				Refresh: soa.Refresh,
+				Retry:   soa.Retry,
+				Expire:  soa.Expire,
+				Minimum: soa.Minttl,
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("no SOA record found for %s: %w", domain, ErrNoData)
+}
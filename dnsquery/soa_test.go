@@ -0,0 +1,27 @@
+package dnsquery
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestGetSOARecord(t *testing.T) {
+	resolver := zoneResolver(t, `example.com. 3600 IN SOA NS1.example.com. hostmaster.example.com. 2024010101 7200 900 1209600 300`)
+
+	soa, err := resolver.GetSOARecord("example.com")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	expected := SOARecord{MName: "ns1.example.com.", RName: "hostmaster.example.com.", Serial: 2024010101, Refresh: 7200, Retry: 900, Expire: 1209600, Minimum: 300}
+	if *soa != expected {
+		t.Fatalf("expected %+v, got %+v", expected, *soa)
+	}
+}
+
+func TestGetSOARecord_NotFound(t *testing.T) {
+	resolver := zoneResolver(t, `example.com. 300 IN TXT "v=spf1 -all"`)
+
+	if _, err := resolver.GetSOARecord("example.com"); !errors.Is(err, ErrNoData) {
+		t.Fatalf("expected ErrNoData, got %v", err)
+	}
+}
@@ -0,0 +1,333 @@
+package dnsquery
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	// SPFLookupLimit is the maximum number of DNS-querying terms an SPF
+	// evaluation may cause (RFC 7208 section 4.6.4).
+	SPFLookupLimit = 10
+	// SPFVoidLookupLimit is the maximum number of lookups returning no
+	// records an SPF evaluation may cause.
+	SPFVoidLookupLimit = 2
+	// spfMaxDepth bounds include and redirect recursion.
+	spfMaxDepth = 10
+)
+
+// errMultipleSPF is returned when a domain publishes more than one SPF record.
+var errMultipleSPF = errors.New("multiple SPF records published")
+
+// SPFMechanism is a single directive of an SPF record, such as "-all" or
+// "include:_spf.example.com".
+type SPFMechanism struct {
+	// Qualifier is one of "+", "-", "~" or "?".
+	Qualifier string
+	Name      string
+	// Value is the domain-spec or network following the ":" or "/", if any.
+	Value string
+	// Include holds the expanded record of an include mechanism.
+	Include *SPFRecord
+}
+
+func (m SPFMechanism) String() string {
+	term := m.Name
+	if m.Qualifier != "+" {
+		term = m.Qualifier + term
+	}
+	if m.Value == "" {
+		return term
+	}
+	if strings.HasPrefix(m.Value, "/") {
+		return term + m.Value
+	}
+	return term + ":" + m.Value
+}
+
+// SPFModifier is a name=value term of an SPF record.
+type SPFModifier struct {
+	Name  string
+	Value string
+}
+
+// SPFRecord is a parsed SPF record. After AnalyzeSPF the include mechanisms
+// and the redirect modifier point at the records they reference.
+type SPFRecord struct {
+	Domain     string
+	Raw        string
+	Mechanisms []SPFMechanism
+	Modifiers  []SPFModifier
+	Redirect   *SPFRecord
+}
+
+// Modifier returns the value of the named modifier, if present.
+func (s *SPFRecord) Modifier(name string) (string, bool) {
+	for _, modifier := range s.Modifiers {
+		if modifier.Name == name {
+			return modifier.Value, true
+		}
+	}
+	return "", false
+}
+
+// isSPFRecord reports whether a TXT string is an SPF version 1 record.
+func isSPFRecord(txt string) bool {
+	fields := strings.Fields(txt)
+	return len(fields) > 0 && strings.EqualFold(fields[0], "v=spf1")
+}
+
+// ParseSPF parses the terms of an SPF record without resolving anything.
+func ParseSPF(record string) (*SPFRecord, error) {
+	if !isSPFRecord(record) {
+		return nil, fmt.Errorf("not an SPF record: %q", record)
+	}
+
+	spf := &SPFRecord{Raw: record}
+	for _, term := range strings.Fields(record)[1:] {
+		if name, value, ok := strings.Cut(term, "="); ok && !strings.ContainsAny(name, ":/") {
+			name = strings.ToLower(name)
+			if _, seen := spf.Modifier(name); seen && (name == "redirect" || name == "exp") {
+				return nil, fmt.Errorf("duplicate %s modifier", name)
+			}
+			spf.Modifiers = append(spf.Modifiers, SPFModifier{Name: name, Value: value})
+			continue
+		}
+
+		mechanism, err := parseSPFMechanism(term)
+		if err != nil {
+			return nil, err
+		}
+		spf.Mechanisms = append(spf.Mechanisms, mechanism)
+	}
+	return spf, nil
+}
+
+// parseSPFMechanism parses and validates a single mechanism term.
+func parseSPFMechanism(term string) (SPFMechanism, error) {
+	mechanism := SPFMechanism{Qualifier: "+"}
+	if strings.ContainsAny(term[:1], "+-~?") {
+		mechanism.Qualifier = term[:1]
+		term = term[1:]
+	}
+
+	name := term
+	if i := strings.IndexAny(term, ":/"); i >= 0 {
+		name = term[:i]
+		mechanism.Value = strings.TrimPrefix(term[i:], ":")
+	}
+	mechanism.Name = strings.ToLower(name)
+
+	switch mechanism.Name {
+	case "all":
+		if mechanism.Value != "" {
+			return mechanism, fmt.Errorf("invalid SPF term %q: all takes no argument", term)
+		}
+	case "include", "exists":
+		if mechanism.Value == "" {
+			return mechanism, fmt.Errorf("invalid SPF term %q: %s requires a domain", term, mechanism.Name)
+		}
+	case "ip4", "ip6":
+		address := mechanism.Value
+		if strings.Contains(address, "/") {
+			ip, _, err := net.ParseCIDR(address)
+			if err != nil {
+				return mechanism, fmt.Errorf("invalid SPF term %q: %v", term, err)
+			}
+			address = ip.String()
+		}
+		ip := net.ParseIP(address)
+		if ip == nil || (mechanism.Name == "ip4") != (ip.To4() != nil) {
+			return mechanism, fmt.Errorf("invalid SPF term %q: bad %s address", term, mechanism.Name)
+		}
+	case "a", "mx", "ptr":
+	default:
+		return mechanism, fmt.Errorf("invalid SPF term %q: unknown mechanism", term)
+	}
+	return mechanism, nil
+}
+
+// SPFAnalysis is the result of expanding the SPF record of a domain.
+type SPFAnalysis struct {
+	Domain string
+	Record *SPFRecord
+	// Lookups counts the terms that cause DNS queries across the whole tree.
+	Lookups int
+	// VoidLookups counts the queries that returned NXDOMAIN or no records.
+	VoidLookups int
+	// Issues lists every problem found: syntax errors, loops, missing or
+	// duplicate records and exceeded limits.
+	Issues []string
+}
+
+// Valid reports whether no issue was found.
+func (a *SPFAnalysis) Valid() bool {
+	return len(a.Issues) == 0
+}
+
+// AnalyzeSPF expands the SPF record of domain using the default resolver.
+func AnalyzeSPF(domain string) (*SPFAnalysis, error) {
+	return defaultResolver.AnalyzeSPF(domain)
+}
+
+// AnalyzeSPF fetches and parses the SPF record of domain, recursively
+// expands include mechanisms and the redirect modifier and checks the
+// RFC 7208 lookup and void lookup limits. An error is returned when the
+// record of domain itself cannot be fetched or parsed, or when a referenced
+// record cannot be looked up for a reason other than NXDOMAIN or NODATA.
+func (r *Resolver) AnalyzeSPF(domain string) (*SPFAnalysis, error) {
+	record, err := r.fetchSPF(domain)
+	if err != nil {
+		return nil, err
+	}
+
+	analysis := &SPFAnalysis{Domain: domain, Record: record}
+	if err := r.expandSPF(analysis, record, []string{canonicalName(domain)}); err != nil {
+		return nil, err
+	}
+
+	if analysis.Lookups > SPFLookupLimit {
+		analysis.addIssue("too many DNS lookups: %d (limit %d)", analysis.Lookups, SPFLookupLimit)
+	}
+	if analysis.VoidLookups > SPFVoidLookupLimit {
+		analysis.addIssue("too many void lookups: %d (limit %d)", analysis.VoidLookups, SPFVoidLookupLimit)
+	}
+	return analysis, nil
+}
+
+func (a *SPFAnalysis) addIssue(format string, args ...interface{}) {
+	a.Issues = append(a.Issues, fmt.Sprintf(format, args...))
+}
+
+// fetchSPF looks up and parses the single SPF record of domain.
+func (r *Resolver) fetchSPF(domain string) (*SPFRecord, error) {
+	txtRecords, err := r.GetTXTRecords(domain)
+	if err != nil {
+		return nil, err
+	}
+
+	var spfRecords []string
+	for _, txt := range txtRecords {
+		if isSPFRecord(txt) {
+			spfRecords = append(spfRecords, txt)
+		}
+	}
+	switch len(spfRecords) {
+	case 0:
+		return nil, fmt.Errorf("no SPF record found for %s: %w", domain, ErrNoData)
+	case 1:
+	default:
+		return nil, fmt.Errorf("%s: %w", domain, errMultipleSPF)
+	}
+
+	record, err := ParseSPF(spfRecords[0])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", domain, err)
+	}
+	record.Domain = domain
+	return record, nil
+}
+
+// expandSPF walks record, counting lookups and resolving referenced records.
+// path holds the domains being expanded, in canonicalName form, to detect
+// loops.
+func (r *Resolver) expandSPF(analysis *SPFAnalysis, record *SPFRecord, path []string) error {
+	hasAll := false
+	for i := range record.Mechanisms {
+		mechanism := &record.Mechanisms[i]
+		target := mechanism.Value
+		if target == "" || strings.HasPrefix(target, "/") {
+			target = record.Domain
+		} else if j := strings.Index(target, "/"); j >= 0 {
+			target = target[:j]
+		}
+
+		switch mechanism.Name {
+		case "all":
+			hasAll = true
+		case "include":
+			analysis.Lookups++
+			include, err := r.expandReference(analysis, record.Domain, "include:"+target, target, path)
+			if err != nil {
+				return err
+			}
+			mechanism.Include = include
+		case "a":
+			analysis.Lookups++
+			r.countVoid(analysis, target, func(name string) error { _, err := r.GetAddresses(name); return err })
+		case "mx":
+			analysis.Lookups++
+			r.countVoid(analysis, target, func(name string) error { _, err := r.GetMXRecords(name); return err })
+		case "exists":
+			analysis.Lookups++
+			r.countVoid(analysis, target, func(name string) error { _, err := r.Query(name, dns.TypeA); return err })
+		case "ptr":
+			analysis.Lookups++
+			analysis.addIssue("%s: ptr mechanism is deprecated", record.Domain)
+		}
+	}
+
+	if target, ok := record.Modifier("redirect"); ok && !hasAll {
+		analysis.Lookups++
+		redirect, err := r.expandReference(analysis, record.Domain, "redirect="+target, target, path)
+		if err != nil {
+			return err
+		}
+		record.Redirect = redirect
+	}
+	return nil
+}
+
+// expandReference fetches and expands the record referenced by an include
+// mechanism or redirect modifier of domain. A missing or broken record is
+// an issue of domain; a failed lookup is returned so the analysis is not
+// based on a partial expansion.
+func (r *Resolver) expandReference(analysis *SPFAnalysis, domain, term, target string, path []string) (*SPFRecord, error) {
+	if strings.Contains(target, "%") {
+		// Macros depend on the message being checked and cannot be expanded here.
+		return nil, nil
+	}
+	for _, visited := range path {
+		if visited == canonicalName(target) {
+			analysis.addIssue("%s: %s creates a loop", domain, term)
+			return nil, nil
+		}
+	}
+	if len(path) >= spfMaxDepth {
+		analysis.addIssue("%s: %s nested too deeply", domain, term)
+		return nil, nil
+	}
+
+	record, err := r.fetchSPF(target)
+	if err != nil {
+		var lookupErr *LookupError
+		switch {
+		case IsNotFound(err):
+			analysis.VoidLookups++
+		case errors.As(err, &lookupErr):
+			return nil, err
+		}
+		analysis.addIssue("%s: %s: %v", domain, term, err)
+		return nil, nil
+	}
+
+	if err := r.expandSPF(analysis, record, append(path, canonicalName(target))); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// countVoid runs lookup for name, unless it contains macros, and counts it
+// as a void lookup when it finds nothing.
+func (r *Resolver) countVoid(analysis *SPFAnalysis, name string, lookup func(string) error) {
+	if strings.Contains(name, "%") {
+		return
+	}
+	if err := lookup(name); IsNotFound(err) {
+		analysis.VoidLookups++
+	}
+}
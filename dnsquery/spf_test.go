@@ -0,0 +1,160 @@
+package dnsquery
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestParseSPF_Terms(t *testing.T) {
+	record, err := ParseSPF("v=spf1 ip4:192.0.2.0/24 a mx:mail.example.com -include:_spf.example.net ~all redirect=_spf.example.org")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(record.Mechanisms) != 5 {
+		t.Fatalf("expected 5 mechanisms, got %d", len(record.Mechanisms))
+	}
+	include := record.Mechanisms[3]
+	if include.Qualifier != "-" || include.Name != "include" || include.Value != "_spf.example.net" {
+		t.Fatalf("unexpected include mechanism: %+v", include)
+	}
+	if record.Mechanisms[4].String() != "~all" {
+		t.Fatalf("unexpected all mechanism: %s", record.Mechanisms[4])
+	}
+	if redirect, ok := record.Modifier("redirect"); !ok || redirect != "_spf.example.org" {
+		t.Fatalf("unexpected redirect: %q", redirect)
+	}
+}
+
+func TestParseSPF_SyntaxErrors(t *testing.T) {
+	for _, record := range []string{
+		"v=spf1 ip4:300.1.1.1 -all",
+		"v=spf1 ip6:192.0.2.1 -all",
+		"v=spf1 include -all",
+		"v=spf1 foo:bar -all",
+		"v=spf1 redirect=a.example redirect=b.example",
+	} {
+		if _, err := ParseSPF(record); err == nil {
+			t.Fatalf("expected syntax error for %q", record)
+		}
+	}
+}
+
+func TestAnalyzeSPF_CountsNestedLookups(t *testing.T) {
+	resolver := zoneResolver(t,
+		`example.com. 300 IN TXT "v=spf1 mx include:_spf.vendor.example ~all"`,
+		`example.com. 300 IN MX 10 mail.example.com.`,
+		`_spf.vendor.example. 300 IN TXT "v=spf1 include:a.vendor.example include:b.vendor.example -all"`,
+		`a.vendor.example. 300 IN TXT "v=spf1 ip4:192.0.2.0/24 -all"`,
+		`b.vendor.example. 300 IN TXT "v=spf1 a:" "relay.vendor.example -all"`,
+		`relay.vendor.example. 300 IN A 192.0.2.10`,
+	)
+
+	analysis, err := resolver.AnalyzeSPF("example.com")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if analysis.Lookups != 5 {
+		t.Fatalf("expected 5 lookups, got %d", analysis.Lookups)
+	}
+	if !analysis.Valid() {
+		t.Fatalf("expected valid record, got issues: %v", analysis.Issues)
+	}
+	vendor := analysis.Record.Mechanisms[1].Include
+	if vendor == nil || vendor.Mechanisms[1].Include == nil {
+		t.Fatalf("expected nested includes to be expanded")
+	}
+}
+
+func TestAnalyzeSPF_LookupLimit(t *testing.T) {
+	spf := "v=spf1"
+	var records []string
+	for i := 0; i < 11; i++ {
+		vendor := fmt.Sprintf("vendor%d.example.net", i)
+		spf += " include:" + vendor
+		records = append(records, vendor+`. 300 IN TXT "v=spf1 ip4:192.0.2.0/24 -all"`)
+	}
+	records = append(records, `example.com. 300 IN TXT "`+spf+` -all"`)
+	resolver := zoneResolver(t, records...)
+
+	analysis, err := resolver.AnalyzeSPF("example.com")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if analysis.Lookups <= SPFLookupLimit {
+		t.Fatalf("expected more than %d lookups, got %d", SPFLookupLimit, analysis.Lookups)
+	}
+	if !strings.Contains(strings.Join(analysis.Issues, "\n"), "too many DNS lookups") {
+		t.Fatalf("expected lookup limit issue, got %v", analysis.Issues)
+	}
+}
+
+func TestAnalyzeSPF_LoopsAndVoidLookups(t *testing.T) {
+	resolver := zoneResolver(t,
+		`example.com. 300 IN TXT "v=spf1 include:loop.example.net include:gone.example.net a:missing1.example.com mx:missing2.example.com -all"`,
+		`loop.example.net. 300 IN TXT "v=spf1 include:example.com -all"`,
+	)
+
+	analysis, err := resolver.AnalyzeSPF("example.com")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	issues := strings.Join(analysis.Issues, "\n")
+	if !strings.Contains(issues, "creates a loop") {
+		t.Fatalf("expected loop issue, got %v", analysis.Issues)
+	}
+	if analysis.VoidLookups != 3 || !strings.Contains(issues, "too many void lookups") {
+		t.Fatalf("expected 3 void lookups, got %d: %v", analysis.VoidLookups, analysis.Issues)
+	}
+}
+
+func TestAnalyzeSPF_InternationalizedLoop(t *testing.T) {
+	resolver := zoneResolver(t,
+		`xn--bcher-kva.de. 300 IN TXT "v=spf1 include:_spf.xn--bcher-kva.de -all"`,
+		`_spf.xn--bcher-kva.de. 300 IN TXT "v=spf1 include:xn--bcher-kva.de -all"`,
+	)
+
+	analysis, err := resolver.AnalyzeSPF("bücher.de")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	// The loop closes at the include of the A-label form of the domain
+	// itself, not one level later
+	want := "_spf.xn--bcher-kva.de: include:xn--bcher-kva.de creates a loop"
+	if len(analysis.Issues) != 1 || analysis.Issues[0] != want {
+		t.Fatalf("expected %q, got %v", want, analysis.Issues)
+	}
+}
+
+func TestAnalyzeSPF_IncludeLookupFails(t *testing.T) {
+	resolver := NewResolver(Config{
+		Servers: []string{"192.0.2.1"},
+		Exchanger: exchangeFunc(func(msg *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+			if msg.Question[0].Name == "example.com." {
+				hdr := dns.RR_Header{Name: "example.com.", Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: 300}
+				return answer(msg, &dns.TXT{Hdr: hdr, Txt: []string{"v=spf1 include:_spf.vendor.example -all"}}), 0, nil
+			}
+			return nil, 0, timeoutError{}
+		}),
+	})
+
+	analysis, err := resolver.AnalyzeSPF("example.com")
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected timeout error, got %v (analysis %+v)", err, analysis)
+	}
+}
+
+func TestAnalyzeSPF_MultipleRecords(t *testing.T) {
+	resolver := zoneResolver(t,
+		`example.com. 300 IN TXT "v=spf1 -all"`,
+		`example.com. 300 IN TXT "v=spf1 ~all"`,
+	)
+
+	if _, err := resolver.AnalyzeSPF("example.com"); err == nil || !strings.Contains(err.Error(), "multiple SPF records") {
+		t.Fatalf("expected multiple records error, got %v", err)
+	}
+}
@@ -0,0 +1,135 @@
+package dnsquery
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Transport selects how queries reach the upstream resolvers.
+type Transport int
+
+const (
+	// TransportUDP sends plain DNS over UDP to port 53.
+	TransportUDP Transport = iota
+	// TransportTCP sends plain DNS over TCP to port 53.
+	TransportTCP
+	// TransportTLS sends DNS over TLS (RFC 7858) to port 853.
+	TransportTLS
+	// TransportHTTPS sends DNS over HTTPS (RFC 8484). Servers are given as
+	// URLs such as https://dns.example/dns-query.
+	TransportHTTPS
+)
+
+// ParseTransport converts a configuration value ("udp", "tcp", "tls" or
+// "https") into a Transport. An empty value selects TransportUDP.
+func ParseTransport(value string) (Transport, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "udp":
+		return TransportUDP, nil
+	case "tcp":
+		return TransportTCP, nil
+	case "tls", "dot":
+		return TransportTLS, nil
+	case "https", "doh":
+		return TransportHTTPS, nil
+	}
+	return TransportUDP, fmt.Errorf("unknown resolver transport %q", value)
+}
+
+// defaultPort is the port assumed for servers configured without one.
+func (t Transport) defaultPort() string {
+	if t == TransportTLS {
+		return "853"
+	}
+	return "53"
+}
+
+// defaultServers is Cloudflare's public resolver reached over t.
+func (t Transport) defaultServers() []string {
+	switch t {
+	case TransportTLS:
+		return []string{"1.1.1.1:853#cloudflare-dns.com"}
+	case TransportHTTPS:
+		return []string{"https://cloudflare-dns.com/dns-query"}
+	}
+	return DefaultConfig().Servers
+}
+
+// tlsExchanger sends queries over DNS over TLS, verifying every server
+// certificate against the name configured for that server.
+type tlsExchanger struct {
+	timeout time.Duration
+	config  *tls.Config
+	// names maps server addresses to the name their certificate must
+	// carry. Servers without one are verified against their host.
+	names map[string]string
+}
+
+func (e *tlsExchanger) ExchangeContext(ctx context.Context, msg *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+	config := &tls.Config{}
+	if e.config != nil {
+		config = e.config.Clone()
+	}
+	config.ServerName = e.names[address]
+	if config.ServerName == "" {
+		config.ServerName, _, _ = net.SplitHostPort(address)
+	}
+
+	client := &dns.Client{Net: "tcp-tls", Timeout: e.timeout, TLSConfig: config}
+	return client.ExchangeContext(ctx, msg, address)
+}
+
+// httpsExchanger sends queries as DNS over HTTPS POST requests in wire
+// format. The address passed to ExchangeContext is the URL of the server.
+type httpsExchanger struct {
+	client *http.Client
+}
+
+func (e *httpsExchanger) ExchangeContext(ctx context.Context, msg *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+	start := time.Now()
+
+	// RFC 8484 section 4.1 asks for ID 0 so replies can be cached
+	query := msg.Copy()
+	query.Id = 0
+	packed, err := query.Pack()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, address, bytes.NewReader(packed))
+	if err != nil {
+		return nil, 0, err
+	}
+	request.Header.Set("Content-Type", "application/dns-message")
+	request.Header.Set("Accept", "application/dns-message")
+
+	response, err := e.client.Do(request)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("DoH server %s answered %s", address, response.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(response.Body, dns.MaxMsgSize))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	reply := new(dns.Msg)
+	if err := reply.Unpack(body); err != nil {
+		return nil, 0, fmt.Errorf("invalid DoH reply from %s: %w", address, err)
+	}
+	reply.Id = msg.Id
+	return reply, time.Since(start), nil
+}
@@ -0,0 +1,174 @@
+package dnsquery
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// testCertificate creates a self-signed certificate for name and 127.0.0.1
+// and returns it with a pool trusting it.
+func testCertificate(t *testing.T, name string) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("creating certificate: %v", err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parsing certificate: %v", err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(certificate)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, pool
+}
+
+// txtHandler answers every query with a single TXT record.
+var txtHandler = dns.HandlerFunc(func(w dns.ResponseWriter, msg *dns.Msg) {
+	hdr := dns.RR_Header{Name: msg.Question[0].Name, Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: 300}
+	w.WriteMsg(answer(msg, &dns.TXT{Hdr: hdr, Txt: []string{"v=spf1 -all"}}))
+})
+
+// dotServer starts a DNS over TLS stand-in on a local port.
+func dotServer(t *testing.T, certificate tls.Certificate) string {
+	t.Helper()
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{certificate}})
+	if err != nil {
+		t.Fatalf("listening: %v", err)
+	}
+	server := &dns.Server{Listener: listener, Net: "tcp-tls", Handler: txtHandler}
+	go server.ActivateAndServe()
+	t.Cleanup(func() { server.Shutdown() })
+	return listener.Addr().String()
+}
+
+func TestParseTransport(t *testing.T) {
+	cases := map[string]Transport{"": TransportUDP, "tcp": TransportTCP, "DoT": TransportTLS, "https": TransportHTTPS}
+	for value, expected := range cases {
+		transport, err := ParseTransport(value)
+		if err != nil || transport != expected {
+			t.Fatalf("ParseTransport(%q) = %v, %v", value, transport, err)
+		}
+	}
+	if _, err := ParseTransport("quic"); err == nil {
+		t.Fatal("expected error for unknown transport")
+	}
+}
+
+func TestNewResolver_TLSDefaultPort(t *testing.T) {
+	resolver := NewResolver(Config{Transport: TransportTLS, Servers: []string{"9.9.9.9#dns.quad9.net", "10.0.0.1:8853"}})
+
+	servers := resolver.Servers()
+	if len(servers) != 2 || servers[0] != "9.9.9.9:853" || servers[1] != "10.0.0.1:8853" {
+		t.Fatalf("unexpected servers: %v", servers)
+	}
+}
+
+func TestNewResolver_DirectQueriesFollowTransport(t *testing.T) {
+	cases := map[Transport]string{TransportUDP: "", TransportTCP: "tcp", TransportTLS: "tcp", TransportHTTPS: "tcp"}
+	for transport, want := range cases {
+		resolver := NewResolver(Config{Transport: transport})
+		client, ok := resolver.direct.(*dns.Client)
+		if !ok || client.Net != want {
+			t.Fatalf("transport %d: expected direct queries over %q, got %+v", transport, want, resolver.direct)
+		}
+	}
+}
+
+func TestQuery_DNSOverTLS(t *testing.T) {
+	certificate, pool := testCertificate(t, "dns.example.test")
+	address := dotServer(t, certificate)
+
+	resolver := NewResolver(Config{
+		Servers:   []string{address + "#dns.example.test"},
+		Transport: TransportTLS,
+		TLSConfig: &tls.Config{RootCAs: pool},
+		Timeout:   2 * time.Second,
+	})
+	records, err := resolver.GetTXTRecords("example.com")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(records) != 1 || records[0] != "v=spf1 -all" {
+		t.Fatalf("unexpected records: %v", records)
+	}
+}
+
+func TestQuery_DNSOverTLSWrongName(t *testing.T) {
+	certificate, pool := testCertificate(t, "dns.example.test")
+	address := dotServer(t, certificate)
+
+	resolver := NewResolver(Config{
+		Servers:   []string{address + "#other.example.test"},
+		Transport: TransportTLS,
+		TLSConfig: &tls.Config{RootCAs: pool},
+		Timeout:   2 * time.Second,
+	})
+	if _, err := resolver.GetTXTRecords("example.com"); err == nil {
+		t.Fatal("expected certificate verification to fail")
+	}
+}
+
+func TestQuery_DNSOverHTTPS(t *testing.T) {
+	certificate, pool := testCertificate(t, "dns.example.test")
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/dns-query" || r.Header.Get("Content-Type") != "application/dns-message" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		msg := new(dns.Msg)
+		if err := msg.Unpack(body); err != nil || msg.Id != 0 {
+			http.Error(w, "bad message", http.StatusBadRequest)
+			return
+		}
+		hdr := dns.RR_Header{Name: msg.Question[0].Name, Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: 300}
+		reply, _ := answer(msg, &dns.TXT{Hdr: hdr, Txt: []string{"v=spf1 -all"}}).Pack()
+		w.Header().Set("Content-Type", "application/dns-message")
+		w.Write(reply)
+	}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{certificate}}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	resolver := NewResolver(Config{
+		Servers:   []string{server.URL + "/dns-query"},
+		Transport: TransportHTTPS,
+		TLSConfig: &tls.Config{RootCAs: pool},
+		Timeout:   2 * time.Second,
+	})
+	records, err := resolver.GetTXTRecords("example.com")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(records) != 1 || records[0] != "v=spf1 -all" {
+		t.Fatalf("unexpected records: %v", records)
+	}
+}
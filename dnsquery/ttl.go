@@ -0,0 +1,203 @@
+package dnsquery
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// RRsetTTL is the TTL of one monitored RRset. Name is relative to the
+// domain, "@" being the apex.
+type RRsetTTL struct {
+	Name string
+	Type string
+	TTL  uint32
+}
+
+func (t RRsetTTL) key() string {
+	return t.Name + " " + t.Type
+}
+
+func (t RRsetTTL) String() string {
+	return fmt.Sprintf("%s %s %d", t.Name, t.Type, t.TTL)
+}
+
+// monitoredRRsets lists the RRsets whose TTLs are tracked, by name relative
+// to the domain and type.
+var monitoredRRsets = []struct {
+	name  string
+	qtype uint16
+}{
+	{"@", dns.TypeSOA},
+	{"@", dns.TypeNS},
+	{"@", dns.TypeMX},
+	{"@", dns.TypeA},
+	{"@", dns.TypeAAAA},
+	{"@", dns.TypeTXT},
+	{"@", dns.TypeCAA},
+	{"_dmarc", dns.TypeTXT},
+	{"www", dns.TypeA},
+	{"www", dns.TypeAAAA},
+}
+
+// TTLPolicy holds the thresholds TTLs are checked against.
+type TTLPolicy struct {
+	// MinNSMX is the lowest TTL expected on the NS and MX RRsets. Lower
+	// values make it easy to redirect the domain or its mail quickly,
+	// which is also what a hijack needs.
+	MinNSMX uint32
+	// Max is the highest TTL expected on any RRset.
+	Max uint32
+	// DropFactor reports a TTL that fell to 1/DropFactor of its previous
+	// value or less, as done ahead of a migration. Zero disables it.
+	DropFactor uint32
+}
+
+// DefaultTTLPolicy returns the thresholds used unless configured otherwise:
+// 1 hour minimum on NS and MX, 1 week maximum and a drop factor of 4.
+func DefaultTTLPolicy() TTLPolicy {
+	return TTLPolicy{MinNSMX: 3600, Max: 7 * 86400, DropFactor: 4}
+}
+
+// Check lists the TTLs outside the policy thresholds.
+func (p TTLPolicy) Check(ttls []RRsetTTL) []string {
+	var issues []string
+	for _, ttl := range ttls {
+		if ttl.Name == "@" && (ttl.Type == "NS" || ttl.Type == "MX") && ttl.TTL < p.MinNSMX {
+			issues = append(issues, fmt.Sprintf("%s TTL %d is below %d", ttl.Type, ttl.TTL, p.MinNSMX))
+		}
+		if p.Max > 0 && ttl.TTL > p.Max {
+			issues = append(issues, fmt.Sprintf("%s %s TTL %d is above %d", ttl.Name, ttl.Type, ttl.TTL, p.Max))
+		}
+	}
+	return issues
+}
+
+// Drops lists the RRsets whose TTL fell by DropFactor or more since the
+// previous check.
+func (p TTLPolicy) Drops(previous, current []RRsetTTL) []string {
+	if p.DropFactor == 0 {
+		return nil
+	}
+	before := map[string]uint32{}
+	for _, ttl := range previous {
+		before[ttl.key()] = ttl.TTL
+	}
+
+	var drops []string
+	for _, ttl := range current {
+		old, ok := before[ttl.key()]
+		if ok && ttl.TTL < old && uint64(ttl.TTL)*uint64(p.DropFactor) <= uint64(old) {
+			drops = append(drops, fmt.Sprintf("%s %s TTL dropped from %d to %d", ttl.Name, ttl.Type, old, ttl.TTL))
+		}
+	}
+	return drops
+}
+
+// FormatTTLs renders TTLs for storage, one "name type ttl" per line.
+func FormatTTLs(ttls []RRsetTTL) string {
+	lines := make([]string, 0, len(ttls))
+	for _, ttl := range ttls {
+		lines = append(lines, ttl.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ParseTTLs reads TTLs stored by FormatTTLs, skipping malformed lines.
+func ParseTTLs(stored string) []RRsetTTL {
+	var ttls []RRsetTTL
+	for _, line := range strings.Split(stored, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			continue
+		}
+		ttl, err := strconv.ParseUint(fields[2], 10, 32)
+		if err != nil {
+			continue
+		}
+		ttls = append(ttls, RRsetTTL{Name: fields[0], Type: fields[1], TTL: uint32(ttl)})
+	}
+	return ttls
+}
+
+// GetTTLs fetches the TTLs of the monitored RRsets of domain using the
+// default resolver.
+func GetTTLs(domain string) ([]RRsetTTL, error) {
+	return defaultResolver.GetTTLs(domain)
+}
+
+// GetTTLs fetches the TTLs of the monitored RRsets of domain. They are asked
+// from an authoritative nameserver, since a caching resolver counts TTLs
+// down and would report a different value on every check. The nameservers
+// are tried in sorted order and lame ones are skipped, so the same server
+// answers on every check. RRsets that do not exist are left out; a name
+// answered with a CNAME is reported with the TTL of the CNAME.
+func (r *Resolver) GetTTLs(domain string) ([]RRsetTTL, error) {
+	domain, err := ToASCII(domain)
+	if err != nil {
+		return nil, err
+	}
+	nameservers, err := r.GetNSRecords(domain)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(nameservers)
+
+	for _, nameserver := range nameservers {
+		var ttls []RRsetTTL
+		ttls, err = r.authoritativeTTLs(nameserver, domain)
+		if err == nil {
+			return ttls, nil
+		}
+	}
+	return nil, err
+}
+
+// authoritativeTTLs collects the monitored TTLs from a single nameserver.
+func (r *Resolver) authoritativeTTLs(nameserver, domain string) ([]RRsetTTL, error) {
+	address, err := r.nameserverAddress(nameserver)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		ttls []RRsetTTL
+		seen = map[string]bool{}
+	)
+	for _, rrset := range monitoredRRsets {
+		name := domain
+		if rrset.name != "@" {
+			name = rrset.name + "." + domain
+		}
+		response, err := r.QueryServer(address, name, rrset.qtype)
+		if err != nil {
+			return nil, err
+		}
+		if response.Rcode != dns.RcodeSuccess && response.Rcode != dns.RcodeNameError {
+			return nil, lookupError(name, rrset.qtype, response, nil)
+		}
+		if !response.Authoritative {
+			return nil, fmt.Errorf("lame delegation: %s is not authoritative for %s", nameserver, domain)
+		}
+
+		for _, record := range response.Answer {
+			header := record.Header()
+			if !strings.EqualFold(header.Name, dns.Fqdn(name)) {
+				continue
+			}
+			if header.Rrtype != rrset.qtype && header.Rrtype != dns.TypeCNAME {
+				continue
+			}
+			ttl := RRsetTTL{Name: rrset.name, Type: dns.TypeToString[header.Rrtype], TTL: header.Ttl}
+			if !seen[ttl.key()] {
+				seen[ttl.key()] = true
+				ttls = append(ttls, ttl)
+			}
+			break
+		}
+	}
+	return ttls, nil
+}
@@ -0,0 +1,92 @@
+package dnsquery
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetTTLs(t *testing.T) {
+	resolver := zoneResolver(t,
+		`example.com. 86400 IN NS ns1.example.com.`,
+		`example.com. 300 IN MX 10 mail.example.com.`,
+		`example.com. 3600 IN A 192.0.2.10`,
+		`_dmarc.example.com. 600 IN TXT "v=DMARC1; p=reject"`,
+		`www.example.com. 60 IN A 192.0.2.10`,
+		`ns1.example.com. 3600 IN A 192.0.2.53`,
+	)
+
+	ttls, err := resolver.GetTTLs("example.com")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	expected := []RRsetTTL{
+		{"@", "NS", 86400},
+		{"@", "MX", 300},
+		{"@", "A", 3600},
+		{"_dmarc", "TXT", 600},
+		{"www", "A", 60},
+	}
+	if !reflect.DeepEqual(ttls, expected) {
+		t.Fatalf("expected %v, got %v", expected, ttls)
+	}
+	if parsed := ParseTTLs(FormatTTLs(ttls)); !reflect.DeepEqual(parsed, expected) {
+		t.Fatalf("TTLs did not survive formatting: %v", parsed)
+	}
+}
+
+func TestTTLPolicy_Check(t *testing.T) {
+	policy := TTLPolicy{MinNSMX: 3600, Max: 86400}
+
+	issues := policy.Check([]RRsetTTL{
+		{"@", "NS", 300},
+		{"@", "MX", 3600},
+		{"@", "A", 60},
+		{"www", "A", 172800},
+	})
+	expected := []string{"NS TTL 300 is below 3600", "www A TTL 172800 is above 86400"}
+	if !reflect.DeepEqual(issues, expected) {
+		t.Fatalf("expected %v, got %v", expected, issues)
+	}
+}
+
+func TestTTLPolicy_Drops(t *testing.T) {
+	policy := TTLPolicy{DropFactor: 4}
+	previous := []RRsetTTL{{"@", "NS", 86400}, {"@", "MX", 3600}, {"@", "A", 3600}}
+	current := []RRsetTTL{{"@", "NS", 300}, {"@", "MX", 1800}, {"@", "A", 3600}, {"www", "A", 60}}
+
+	drops := policy.Drops(previous, current)
+	if len(drops) != 1 || drops[0] != "@ NS TTL dropped from 86400 to 300" {
+		t.Fatalf("unexpected drops: %v", drops)
+	}
+}
+
+func TestGetTTLs_SkipsLameNameserver(t *testing.T) {
+	// Each nameserver publishes a different NS TTL; ns1 sorts first but
+	// is lame, so ns2 answers, whatever order the NS set comes in
+	nameserver := func(ttl string) []string {
+		return []string{
+			"example.com. " + ttl + " IN NS ns3.example.com.",
+			"example.com. " + ttl + " IN NS ns1.example.com.",
+			"example.com. " + ttl + " IN NS ns2.example.com.",
+		}
+	}
+	resolver := zonesResolver(t, Config{Servers: []string{"198.51.100.1"}}, map[string][]string{
+		"198.51.100.1": append(nameserver("86400"),
+			`ns1.example.com. 3600 IN A 192.0.2.1`,
+			`ns2.example.com. 3600 IN A 192.0.2.2`,
+			`ns3.example.com. 3600 IN A 192.0.2.3`,
+		),
+		"192.0.2.1": nameserver("60"),
+		"192.0.2.2": nameserver("3600"),
+		"192.0.2.3": nameserver("7200"),
+	})
+	lameServer(resolver, "192.0.2.1")
+
+	ttls, err := resolver.GetTTLs("example.com")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(ttls) != 1 || ttls[0] != (RRsetTTL{"@", "NS", 3600}) {
+		t.Fatalf("expected the TTLs of ns2, got %v", ttls)
+	}
+}
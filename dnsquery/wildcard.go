@@ -0,0 +1,78 @@
+package dnsquery
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// wildcardTypes lists the record types probed for wildcards.
+var wildcardTypes = []uint16{dns.TypeA, dns.TypeAAAA, dns.TypeTXT, dns.TypeMX}
+
+// wildcardLabel returns a label that is very unlikely to exist, so that an
+// answer for it can only come from a wildcard. Tests override it.
+var wildcardLabel = func() string {
+	random := make([]byte, 8)
+	if _, err := rand.Read(random); err != nil {
+		panic(err)
+	}
+	return "wildcard-probe-" + hex.EncodeToString(random)
+}
+
+// GetWildcardRecords probes domain for wildcards using the default resolver.
+func GetWildcardRecords(domain string) ([]string, error) {
+	return defaultResolver.GetWildcardRecords(domain)
+}
+
+// GetWildcardRecords looks up a random label under domain and returns the
+// records it was answered with, as "TYPE data" sorted, e.g. "A 192.0.2.1" or
+// "CNAME parking.example.net.". An empty result means domain has no A,
+// AAAA, TXT or MX wildcard. A CNAME wildcard is returned as the CNAME only,
+// as the records of its target change with the target's hosting. Errors are
+// only returned for failed lookups.
+func (r *Resolver) GetWildcardRecords(domain string) ([]string, error) {
+	domain, err := ToASCII(domain)
+	if err != nil {
+		return nil, err
+	}
+	probe := dns.Fqdn(wildcardLabel() + "." + domain)
+
+	seen := map[string]bool{}
+	var records []string
+	for _, qtype := range wildcardTypes {
+		answer, err := r.Query(probe, qtype)
+		if IsNotFound(err) {
+			// Without a wildcard the probe does not exist at all
+			if errors.Is(err, ErrNXDomain) {
+				break
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, record := range answer {
+			// Records of a CNAME target are owned by the target, not the probe
+			header := record.Header()
+			if header.Rrtype != qtype && header.Rrtype != dns.TypeCNAME {
+				continue
+			}
+			if !strings.EqualFold(header.Name, probe) {
+				continue
+			}
+			data := strings.TrimSpace(strings.TrimPrefix(record.String(), header.String()))
+			value := dns.TypeToString[header.Rrtype] + " " + data
+			if !seen[value] {
+				seen[value] = true
+				records = append(records, value)
+			}
+		}
+	}
+	sort.Strings(records)
+	return records, nil
+}
@@ -0,0 +1,94 @@
+package dnsquery
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// fixedWildcardLabel makes the wildcard probe predictable for the test.
+func fixedWildcardLabel(t *testing.T) {
+	t.Helper()
+	old := wildcardLabel
+	wildcardLabel = func() string { return "probe" }
+	t.Cleanup(func() { wildcardLabel = old })
+}
+
+func TestGetWildcardRecords_NoWildcard(t *testing.T) {
+	fixedWildcardLabel(t)
+	resolver := zoneResolver(t, "example.com. 300 IN A 192.0.2.1")
+
+	records, err := resolver.GetWildcardRecords("example.com")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(records) != 0 {
+		t.Fatalf("expected no wildcard, got %v", records)
+	}
+}
+
+func TestGetWildcardRecords_ReportsSynthesizedRecords(t *testing.T) {
+	fixedWildcardLabel(t)
+	resolver := zoneResolver(t,
+		"probe.example.com. 300 IN A 192.0.2.10",
+		"probe.example.com. 300 IN TXT \"v=spf1 -all\"",
+		"probe.example.com. 300 IN MX 10 mail.example.com.",
+	)
+
+	records, err := resolver.GetWildcardRecords("example.com")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	want := []string{"A 192.0.2.10", "MX 10 mail.example.com.", "TXT \"v=spf1 -all\""}
+	if !reflect.DeepEqual(records, want) {
+		t.Fatalf("unexpected records: %q", records)
+	}
+}
+
+func TestGetWildcardRecords_ReportsWildcardCNAME(t *testing.T) {
+	fixedWildcardLabel(t)
+	resolver := NewResolver(Config{
+		Servers: []string{"192.0.2.1"},
+		Exchanger: exchangeFunc(func(msg *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+			question := msg.Question[0]
+			cname := &dns.CNAME{Hdr: dns.RR_Header{Name: question.Name, Rrtype: dns.TypeCNAME, Class: dns.ClassINET, Ttl: 300}, Target: "parking.example.net."}
+			if question.Qtype != dns.TypeA {
+				return answer(msg, cname), 0, nil
+			}
+			a := &dns.A{Hdr: dns.RR_Header{Name: "parking.example.net.", Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 300}, A: []byte{198, 51, 100, 7}}
+			return answer(msg, cname, a), 0, nil
+		}),
+	})
+
+	records, err := resolver.GetWildcardRecords("example.com")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	want := []string{"CNAME parking.example.net."}
+	if !reflect.DeepEqual(records, want) {
+		t.Fatalf("unexpected records: %q", records)
+	}
+}
+
+func TestGetWildcardRecords_LookupFailure(t *testing.T) {
+	fixedWildcardLabel(t)
+	resolver := NewResolver(Config{
+		Servers: []string{"192.0.2.1"},
+		Exchanger: exchangeFunc(func(msg *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+			return nil, 0, timeoutError{}
+		}),
+	})
+
+	if _, err := resolver.GetWildcardRecords("example.com"); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected ErrTimeout, got %v", err)
+	}
+}
+
+func TestWildcardLabel_IsRandom(t *testing.T) {
+	if wildcardLabel() == wildcardLabel() {
+		t.Fatalf("expected a new label on every probe")
+	}
+}
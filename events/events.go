@@ -8,9 +8,56 @@ import (
 type EventType string
 
 const (
-	EventTypeDmarc       EventType = "UPDATE_DMARC"
-	EventTypeSpf         EventType = "UPDATE_SPF"
-	EventTypeNameservers EventType = "UPDATE_NAMESERVERS"
+	EventTypeDmarc         EventType = "UPDATE_DMARC"
+	EventTypeSpf           EventType = "UPDATE_SPF"
+	EventTypeNameservers   EventType = "UPDATE_NAMESERVERS"
+	EventTypeDnssec        EventType = "UPDATE_DNSSEC"
+	EventTypeMx            EventType = "UPDATE_MX"
+	EventTypeApexAddresses EventType = "UPDATE_APEX_ADDRESSES"
+	EventTypeWwwAddresses  EventType = "UPDATE_WWW_ADDRESSES"
+	EventTypeCaa           EventType = "UPDATE_CAA"
+	EventTypeSpfIssues     EventType = "UPDATE_SPF_ISSUES"
+	EventTypeDmarcIssues   EventType = "UPDATE_DMARC_ISSUES"
+	EventTypeMtaSts        EventType = "UPDATE_MTA_STS"
+	EventTypeMtaStsIssues  EventType = "UPDATE_MTA_STS_ISSUES"
+	EventTypeTlsRpt        EventType = "UPDATE_TLS_RPT"
+	EventTypeBimi          EventType = "UPDATE_BIMI"
+	EventTypeBimiIssues    EventType = "UPDATE_BIMI_ISSUES"
+	EventTypeSoa           EventType = "UPDATE_SOA"
+	// EventTypeSoaSerial is raised whenever the zone serial changes, even
+	// when no monitored record did, as a hint that the zone was edited.
+	EventTypeSoaSerial EventType = "UPDATE_SOA_SERIAL"
+	EventTypeTtls      EventType = "UPDATE_TTLS"
+	EventTypeTtlIssues EventType = "UPDATE_TTL_ISSUES"
+	// EventTypeTtlDrop is raised when a TTL falls sharply, as is done ahead
+	// of a migration or a hijack.
+	EventTypeTtlDrop EventType = "TTL_DROP"
+	// EventTypeWildcard is raised when the records of an existing wildcard
+	// change or the wildcard is removed.
+	EventTypeWildcard EventType = "UPDATE_WILDCARD"
+	// EventTypeWildcardDetected is raised when a domain that had no
+	// wildcard starts answering for any name under it.
+	EventTypeWildcardDetected EventType = "WILDCARD_DETECTED"
+	// EventTypeNameserverMismatch is raised when the authoritative
+	// nameservers of a domain disagree or do not answer authoritatively.
+	EventTypeNameserverMismatch EventType = "NAMESERVER_MISMATCH"
+	// EventTypeAxfrExposed is raised on every check that finds nameservers
+	// allowing anyone to transfer the zone.
+	EventTypeAxfrExposed EventType = "AXFR_EXPOSED"
+	// EventTypeResolverDisagreement is raised when resolvers queried for a
+	// consensus answered differently, as during partial propagation or
+	// when one of them is poisoned.
+	EventTypeResolverDisagreement EventType = "RESOLVER_DISAGREEMENT"
+	// DKIM events carry the selector and key details in Details.
+	EventTypeDkimAdded   EventType = "DKIM_ADDED"
+	EventTypeDkimRemoved EventType = "DKIM_REMOVED"
+	EventTypeDkimRotated EventType = "DKIM_ROTATED"
+	// EventTypeCnameChain is raised when the CNAME chain of a monitored
+	// subdomain changes.
+	EventTypeCnameChain EventType = "UPDATE_CNAME_CHAIN"
+	// EventTypeDanglingCname is raised on every check that finds a monitored
+	// subdomain whose CNAME chain ends in NXDOMAIN.
+	EventTypeDanglingCname EventType = "DANGLING_CNAME"
 )
 
 type EventAction string
@@ -18,6 +65,8 @@ type EventAction string
 const (
 	EventActionChange EventAction = "ACTION_CHANGE"
 	EventActionInsert EventAction = "ACTION_INSERT"
+	EventActionAlert  EventAction = "ACTION_ALERT"
+	EventActionDelete EventAction = "ACTION_DELETE"
 )
 
 type Event struct {
@@ -26,6 +75,9 @@ type Event struct {
 	ExecuteTime    time.Time
 	DomainInfo     models.DomainInfo
 	DomainInfoPrev models.DomainInfo
+	// Details describes the finding for alerts that are not a plain
+	// before/after change.
+	Details string
 }
 
 func (e Event) GetEventType() EventType {
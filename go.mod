@@ -3,12 +3,16 @@ module domain-tool-updater
 go 1.21
 
 require (
-	github.com/lib/pq v1.10.9 // indirect
-	github.com/likexian/whois v1.15.4 // indirect
-	github.com/miekg/dns v1.1.61 // indirect
+	github.com/lib/pq v1.10.9
+	github.com/likexian/whois v1.15.4
+	github.com/miekg/dns v1.1.61
+	golang.org/x/net v0.27.0
+)
+
+require (
 	golang.org/x/mod v0.18.0 // indirect
-	golang.org/x/net v0.27.0 // indirect
 	golang.org/x/sync v0.7.0 // indirect
 	golang.org/x/sys v0.22.0 // indirect
+	golang.org/x/text v0.16.0 // indirect
 	golang.org/x/tools v0.22.0 // indirect
 )
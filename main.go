@@ -2,14 +2,17 @@
 package main
 
 import (
+	"context"
 	"domain-tool-updater/database"
 	"domain-tool-updater/dnsquery"
 	"domain-tool-updater/events"
 	"domain-tool-updater/models"
 	"domain-tool-updater/subscribers"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -24,6 +27,226 @@ func joinMapByColon(m map[string]string) string {
 	return strings.Join(pairs, ", ")
 }
 
+// resolverConfigFromEnv builds the DNS resolver configuration from the
+// environment, keeping the dnsquery defaults for anything left unset.
+func resolverConfigFromEnv() dnsquery.Config {
+	config := dnsquery.DefaultConfig()
+
+	// Left empty, NewResolver picks the default servers of the transport
+	config.Servers = nil
+	if servers := os.Getenv("DNS_SERVERS"); servers != "" {
+		for _, server := range strings.Split(servers, ",") {
+			if server = strings.TrimSpace(server); server != "" {
+				config.Servers = append(config.Servers, server)
+			}
+		}
+	}
+
+	strategy, err := dnsquery.ParseStrategy(os.Getenv("DNS_STRATEGY"))
+	if err != nil {
+		log.Fatalf("Invalid DNS_STRATEGY: %v", err)
+	}
+	config.Strategy = strategy
+
+	transport, err := dnsquery.ParseTransport(os.Getenv("DNS_TRANSPORT"))
+	if err != nil {
+		log.Fatalf("Invalid DNS_TRANSPORT: %v", err)
+	}
+	config.Transport = transport
+
+	if timeout := os.Getenv("DNS_TIMEOUT"); timeout != "" {
+		config.Timeout, err = time.ParseDuration(timeout)
+		if err != nil {
+			log.Fatalf("Invalid DNS_TIMEOUT: %v", err)
+		}
+	}
+
+	if size := os.Getenv("DNS_UDP_SIZE"); size != "" {
+		udpSize, err := strconv.ParseUint(size, 10, 16)
+		if err != nil {
+			log.Fatalf("Invalid DNS_UDP_SIZE: %v", err)
+		}
+		config.UDPSize = uint16(udpSize)
+	}
+
+	if size := os.Getenv("DNS_CACHE_SIZE"); size != "" {
+		config.CacheSize, err = strconv.Atoi(size)
+		if err != nil {
+			log.Fatalf("Invalid DNS_CACHE_SIZE: %v", err)
+		}
+	}
+
+	if quorum := os.Getenv("DNS_QUORUM"); quorum != "" {
+		config.Quorum, err = strconv.Atoi(quorum)
+		if err != nil {
+			log.Fatalf("Invalid DNS_QUORUM: %v", err)
+		}
+	}
+
+	if retries := os.Getenv("DNS_RETRIES"); retries != "" {
+		config.Retries, err = strconv.Atoi(retries)
+		if err != nil {
+			log.Fatalf("Invalid DNS_RETRIES: %v", err)
+		}
+	}
+
+	return config
+}
+
+// defaultDomainTimeout bounds the lookups of a single domain unless
+// DOMAIN_TIMEOUT says otherwise.
+const defaultDomainTimeout = 2 * time.Minute
+
+// domainTimeoutFromEnv returns how long the lookups of one domain may take
+// before the remaining ones are abandoned.
+func domainTimeoutFromEnv() time.Duration {
+	timeout := os.Getenv("DOMAIN_TIMEOUT")
+	if timeout == "" {
+		return defaultDomainTimeout
+	}
+	duration, err := time.ParseDuration(timeout)
+	if err != nil || duration <= 0 {
+		log.Fatalf("Invalid DOMAIN_TIMEOUT: %q", timeout)
+	}
+	return duration
+}
+
+// ttlPolicyFromEnv builds the TTL warning thresholds from the environment,
+// keeping the dnsquery defaults for anything left unset.
+func ttlPolicyFromEnv() dnsquery.TTLPolicy {
+	policy := dnsquery.DefaultTTLPolicy()
+
+	if minTTL := os.Getenv("TTL_MIN_NS_MX"); minTTL != "" {
+		duration, err := time.ParseDuration(minTTL)
+		if err != nil {
+			log.Fatalf("Invalid TTL_MIN_NS_MX: %v", err)
+		}
+		policy.MinNSMX = uint32(duration.Seconds())
+	}
+
+	if maxTTL := os.Getenv("TTL_MAX"); maxTTL != "" {
+		duration, err := time.ParseDuration(maxTTL)
+		if err != nil {
+			log.Fatalf("Invalid TTL_MAX: %v", err)
+		}
+		policy.Max = uint32(duration.Seconds())
+	}
+
+	if factor := os.Getenv("TTL_DROP_FACTOR"); factor != "" {
+		dropFactor, err := strconv.ParseUint(factor, 10, 32)
+		if err != nil {
+			log.Fatalf("Invalid TTL_DROP_FACTOR: %v", err)
+		}
+		policy.DropFactor = uint32(dropFactor)
+	}
+
+	return policy
+}
+
+// lookupValue picks the value to record after a lookup. A record that is
+// gone (NXDOMAIN or NODATA) is recorded as empty, while a failed lookup keeps
+// the stored value so a flaky resolver is never reported as a change. The
+// returned bool is false when the stored value was kept.
+func lookupValue(record, domainName, value, stored string, err error) (string, bool) {
+	if err == nil {
+		return value, true
+	}
+	if dnsquery.IsNotFound(err) {
+		log.Printf("Domain %s Record not found %s", record, domainName)
+		return "", true
+	}
+	log.Printf("ERROR: %s lookup failed for %s, keeping stored value: %v", record, domainName, err)
+	return stored, false
+}
+
+// mtaStsValues picks the MTA-STS id, mode, mx and issues to record, like
+// lookupValue does for single records. The stored policy is kept when the
+// TXT lookup fails, the policy host cannot be reached or the MX hosts it is
+// checked against are unknown.
+func mtaStsValues(domainName string, policy *dnsquery.MTASTSPolicy, err error, mxRecords []dnsquery.MXRecord, mxResolved bool, stored models.DomainInfo) (id, mode, mx, issues string, resolved bool) {
+	switch {
+	case err == nil && mxResolved:
+		allIssues := append(policy.Issues, policy.CheckMX(mxRecords)...)
+		return policy.ID, policy.Mode, strings.Join(policy.MX, ", "), strings.Join(allIssues, "\n"), true
+	case dnsquery.IsNotFound(err):
+		return "", "", "", "", true
+	case err != nil:
+		log.Printf("ERROR: MTA-STS lookup failed for %s, keeping stored policy: %v", domainName, err)
+	}
+	return stored.MtaStsId, stored.MtaStsMode, stored.MtaStsMx, stored.MtaStsIssues, false
+}
+
+// changeDetails explains a change in words for the records that support it.
+func changeDetails(eventType events.EventType, stored, value string) string {
+	switch eventType {
+	case events.EventTypeDmarc:
+		return dnsquery.DescribeDMARCChange(stored, value)
+	}
+	return ""
+}
+
+// mtaStsChanges lists the MTA-STS policy fields that differ between the
+// stored and the current domain, one per line.
+func mtaStsChanges(previous, current models.DomainInfo) string {
+	var changes []string
+	for _, field := range []struct {
+		name          string
+		before, after string
+	}{
+		{"id", previous.MtaStsId, current.MtaStsId},
+		{"mode", previous.MtaStsMode, current.MtaStsMode},
+		{"mx", previous.MtaStsMx, current.MtaStsMx},
+	} {
+		if field.before != field.after {
+			changes = append(changes, fmt.Sprintf("%s changed from %q to %q", field.name, field.before, field.after))
+		}
+	}
+	return strings.Join(changes, "\n")
+}
+
+// soaChanges describes the differences in the SOA fields other than the
+// serial, which is reported on its own.
+func soaChanges(previous, current models.DomainInfo) string {
+	var changes []string
+	for _, field := range []struct {
+		name          string
+		before, after string
+	}{
+		{"mname", previous.SoaMname, current.SoaMname},
+		{"rname", previous.SoaRname, current.SoaRname},
+		{"refresh", fmt.Sprint(previous.SoaRefresh), fmt.Sprint(current.SoaRefresh)},
+		{"retry", fmt.Sprint(previous.SoaRetry), fmt.Sprint(current.SoaRetry)},
+		{"expire", fmt.Sprint(previous.SoaExpire), fmt.Sprint(current.SoaExpire)},
+		{"minimum", fmt.Sprint(previous.SoaMinimum), fmt.Sprint(current.SoaMinimum)},
+	} {
+		if field.before != field.after {
+			changes = append(changes, fmt.Sprintf("%s changed from %q to %q", field.name, field.before, field.after))
+		}
+	}
+	return strings.Join(changes, "\n")
+}
+
+// detectChange notifies the observer with an event of eventType when value
+// differs from the one stored in column, and reports whether it did. A column
+// that was never recorded has nothing to compare against.
+func detectChange(observer *Observer, eventType events.EventType, record, column string, current, previous models.DomainInfo, value, stored string) bool {
+	if value == stored || !previous.Recorded(column) {
+		return false
+	}
+
+	event := events.Event{
+		EventType:      eventType,
+		EventAction:    events.EventActionChange,
+		ExecuteTime:    time.Now(),
+		DomainInfo:     current,
+		DomainInfoPrev: previous,
+		Details:        changeDetails(eventType, stored, value),
+	}
+	observer.Notify(event)
+	log.Printf("%s change detected for domain %s. Old: %s, New: %s", record, current.Name, stored, value)
+	return true
+}
+
 func main() {
 
 	// Initialize Observer and register SMTP subscriber
@@ -32,6 +255,10 @@ func main() {
 
 	fmt.Println("Started Updater...")
 
+	baseResolver := dnsquery.NewResolver(resolverConfigFromEnv())
+	ttlPolicy := ttlPolicyFromEnv()
+	domainTimeout := domainTimeoutFromEnv()
+
 	dbUser := os.Getenv("DB_USER")
 	dbPassword := os.Getenv("DB_PASSWORD")
 	dbHost := os.Getenv("DB_HOST")
@@ -50,38 +277,221 @@ func main() {
 	}
 
 	for _, domain := range domains {
+		// Names are queried as A-labels and shown as U-labels
+		nameAscii, nameUnicode, err := dnsquery.NormalizeDomain(domain.Name)
+		if err != nil {
+			log.Printf("ERROR: Skipping domain with invalid name: %v", err)
+			continue
+		}
+		if nameAscii != domain.NameAscii || nameUnicode != domain.NameUnicode {
+			if err := database.UpdateDomainNames(domain.Name, nameAscii, nameUnicode); err != nil {
+				log.Printf("Error trying to store the name forms of domain %s: %v", domain.Name, err)
+			}
+			domain.NameAscii, domain.NameUnicode = nameAscii, nameUnicode
+		}
+
 		domain_stored, StorageErr := database.GetDomainInfoHistory(domain.Name)
 
+		// Values to keep when a lookup fails rather than reporting a change
+		stored := domain
+		if StorageErr == nil {
+			stored = *domain_stored
+		}
+
 		log.Println("Domain being checked: ", domain.Name)
-		nsRecords, err := dnsquery.GetNSRecords(domain.Name)
+
+		// A domain whose servers stopped answering must not stall the others
+		ctx, cancel := context.WithTimeout(context.Background(), domainTimeout)
+		resolver := baseResolver.WithContext(ctx)
+
+		// Only collect the resolver disagreements and TCP fallbacks of this domain
+		resolver.Disagreements()
+		resolver.TCPFallbacks()
+
+		nsRecords, err := resolver.GetNSRecords(domain.Name)
 		nsRecordcomma := ""
-		if err != nil {
-			log.Println("Domain NS Record not found ", domain.Name)
+		for _, ns := range nsRecords {
+			nsRecordcomma = nsRecordcomma + ", " + ns
+		}
+		nsRecordcomma, resolved := lookupValue("NS", domain.Name, nsRecordcomma, stored.Nameservers, err)
+		if resolved {
+			database.UpdateNS(domain.Name, nsRecordcomma)
+		}
+
+		dmarcRecord, dmarcIssues := "", ""
+		dmarc, err := resolver.AnalyzeDMARC(domain.Name)
+		if err == nil {
+			dmarcRecord, dmarcIssues = dmarc.Raw, strings.Join(dmarc.Issues, "\n")
+		}
+		dmarcRecord, resolved = lookupValue("DMARC", domain.Name, dmarcRecord, stored.Dmarc, err)
+		if resolved {
+			database.UpdateDMARC(domain.Name, dmarcRecord)
+			database.UpdateDMARCIssues(domain.Name, dmarcIssues)
 		} else {
-			for _, ns := range nsRecords {
-				nsRecordcomma = nsRecordcomma + ", " + ns
+			dmarcIssues = stored.DmarcIssues
+		}
+		if dmarcIssues != "" {
+			log.Printf("WARNING: DMARC issues for domain %s: %s", domain.Name, strings.ReplaceAll(dmarcIssues, "\n", "; "))
+		}
+
+		spfRecord, err := resolver.GetSPFRecord(domain.Name)
+		spfRecord, resolved = lookupValue("SPF", domain.Name, spfRecord, stored.Spf, err)
+		if resolved {
+			database.UpdateSPF(domain.Name, spfRecord)
+		}
+
+		status, err := resolver.GetDNSSECStatus(domain.Name)
+		dnssecStatus, resolved := lookupValue("DNSSEC", domain.Name, string(status), stored.Dnssec, err)
+		if resolved {
+			database.UpdateDNSSEC(domain.Name, dnssecStatus)
+		}
+
+		mxRecords, err := resolver.GetMXRecords(domain.Name)
+		mxRecord, resolved := lookupValue("MX", domain.Name, dnsquery.FormatMXRecords(mxRecords), stored.Mx, err)
+		if resolved {
+			database.UpdateMX(domain.Name, mxRecord)
+		}
+		mxResolved := resolved
+
+		apexAddresses, err := resolver.GetAddresses(domain.Name)
+		apexAddress, resolved := lookupValue("Apex address", domain.Name, strings.Join(apexAddresses, ", "), stored.ApexAddresses, err)
+		if resolved {
+			database.UpdateApexAddresses(domain.Name, apexAddress)
+		}
+
+		wwwAddresses, err := resolver.GetAddresses("www." + domain.Name)
+		wwwAddress, resolved := lookupValue("www address", domain.Name, strings.Join(wwwAddresses, ", "), stored.WwwAddresses, err)
+		if resolved {
+			database.UpdateWwwAddresses(domain.Name, wwwAddress)
+		}
+
+		caaIssuers := ""
+		caa, err := resolver.GetCAARecords(domain.Name)
+		if err == nil {
+			caaIssuers = caa.Summary()
+			if !caa.Restricted() {
+				log.Printf("WARNING: Domain %s has no CAA issuers (%s), any CA may issue certificates for it", domain.Name, caaIssuers)
 			}
 		}
-		database.UpdateNS(domain.Name, nsRecordcomma)
+		caaIssuers, resolved = lookupValue("CAA", domain.Name, caaIssuers, stored.Caa, err)
+		if resolved {
+			database.UpdateCAA(domain.Name, caaIssuers)
+		}
 
-		dmarcRecord, err := dnsquery.GetDMARCRecord(domain.Name)
-		if err != nil {
-			log.Println("Domain DMARC Record not found ", domain.Name)
+		// Expand the SPF record to catch includes pushing it over the lookup limit
+		spfLookups, spfIssues := stored.SpfLookups, stored.SpfIssues
+		analysis, err := resolver.AnalyzeSPF(domain.Name)
+		var lookupErr *dnsquery.LookupError
+		switch {
+		case err == nil:
+			spfLookups, spfIssues = analysis.Lookups, strings.Join(analysis.Issues, "\n")
+		case dnsquery.IsNotFound(err):
+			spfLookups, spfIssues = 0, ""
+		case errors.As(err, &lookupErr):
+			log.Printf("ERROR: SPF analysis failed for %s, keeping stored result: %v", domain.Name, err)
+		default:
+			// The record itself is broken: multiple records or a syntax error
+			spfLookups, spfIssues = 0, err.Error()
+		}
+		database.UpdateSPFLookups(domain.Name, spfLookups)
+		database.UpdateSPFIssues(domain.Name, spfIssues)
+		if spfIssues != "" {
+			log.Printf("WARNING: SPF issues for domain %s: %s", domain.Name, strings.ReplaceAll(spfIssues, "\n", "; "))
 		}
-		database.UpdateDMARC(domain.Name, dmarcRecord)
 
-		spfRecord, err := dnsquery.GetSPFRecord(domain.Name)
-		if err != nil {
-			log.Println("Domain SPF Record not found ", domain.Name)
+		// MTA-STS policy, checked against the MX hosts found above
+		policy, err := resolver.GetMTASTSPolicy(domain.Name)
+		mtaStsId, mtaStsMode, mtaStsMx, mtaStsIssues, resolved := mtaStsValues(domain.Name, policy, err, mxRecords, mxResolved, stored)
+		if resolved {
+			database.UpdateMTASTS(domain.Name, mtaStsId, mtaStsMode, mtaStsMx, mtaStsIssues)
+		}
+		if mtaStsIssues != "" {
+			log.Printf("WARNING: MTA-STS issues for domain %s: %s", domain.Name, strings.ReplaceAll(mtaStsIssues, "\n", "; "))
+		}
+
+		tlsRptRecord, err := resolver.GetTLSRPTRecord(domain.Name)
+		tlsRptRecord, resolved = lookupValue("TLS-RPT", domain.Name, tlsRptRecord, stored.TlsRpt, err)
+		if resolved {
+			database.UpdateTLSRPT(domain.Name, tlsRptRecord)
+		}
+
+		// BIMI is only honoured with an enforcing DMARC policy, so check it
+		// against the DMARC record stored above
+		bimiRecord, bimiIssues := "", ""
+		bimi, err := resolver.GetBIMIRecord(domain.Name)
+		if err == nil {
+			bimi.CheckDMARC(dmarcRecord)
+			bimiRecord, bimiIssues = bimi.Raw, strings.Join(bimi.Issues, "\n")
+		}
+		bimiRecord, resolved = lookupValue("BIMI", domain.Name, bimiRecord, stored.Bimi, err)
+		if resolved {
+			database.UpdateBIMI(domain.Name, bimiRecord)
+			database.UpdateBIMIIssues(domain.Name, bimiIssues)
+		} else {
+			bimiIssues = stored.BimiIssues
+		}
+		if bimiIssues != "" {
+			log.Printf("WARNING: BIMI issues for domain %s: %s", domain.Name, strings.ReplaceAll(bimiIssues, "\n", "; "))
+		}
+
+		// SOA, whose serial tells us when the zone was edited
+		soaMname, soaRname, soaSerial := stored.SoaMname, stored.SoaRname, stored.SoaSerial
+		soaRefresh, soaRetry, soaExpire, soaMinimum := stored.SoaRefresh, stored.SoaRetry, stored.SoaExpire, stored.SoaMinimum
+		soa, err := resolver.GetSOARecord(domain.Name)
+		switch {
+		case err == nil:
+			soaMname, soaRname, soaSerial = soa.MName, soa.RName, int(soa.Serial)
+			soaRefresh, soaRetry, soaExpire, soaMinimum = int(soa.Refresh), int(soa.Retry), int(soa.Expire), int(soa.Minimum)
+			database.UpdateSOA(domain.Name, soaMname, soaRname, soaSerial, soaRefresh, soaRetry, soaExpire, soaMinimum)
+		case dnsquery.IsNotFound(err):
+			log.Printf("Domain SOA Record not found %s", domain.Name)
+			soaMname, soaRname, soaSerial = "", "", 0
+			soaRefresh, soaRetry, soaExpire, soaMinimum = 0, 0, 0, 0
+			database.UpdateSOA(domain.Name, soaMname, soaRname, soaSerial, soaRefresh, soaRetry, soaExpire, soaMinimum)
+		default:
+			log.Printf("ERROR: SOA lookup failed for %s, keeping stored value: %v", domain.Name, err)
+		}
+
+		// TTLs of the monitored RRsets, checked against the configured thresholds
+		ttls, err := resolver.GetTTLs(domain.Name)
+		ttlRecord, resolved := lookupValue("TTL", domain.Name, dnsquery.FormatTTLs(ttls), stored.Ttls, err)
+		ttlIssues := stored.TtlIssues
+		if resolved {
+			ttlIssues = strings.Join(ttlPolicy.Check(ttls), "\n")
+			database.UpdateTTLs(domain.Name, ttlRecord, ttlIssues)
+		}
+		if ttlIssues != "" {
+			log.Printf("WARNING: TTL issues for domain %s: %s", domain.Name, strings.ReplaceAll(ttlIssues, "\n", "; "))
+		}
+
+		// Wildcards make any name under the domain resolve
+		wildcards, err := resolver.GetWildcardRecords(domain.Name)
+		wildcardRecord, resolved := lookupValue("Wildcard", domain.Name, strings.Join(wildcards, "\n"), stored.Wildcard, err)
+		if resolved {
+			database.UpdateWildcard(domain.Name, wildcardRecord)
+		}
+
+		// Nameservers that hand the whole zone to anyone asking
+		axfrExposed := ""
+		transfers, err := resolver.CheckZoneTransfer(domain.Name)
+		if err == nil {
+			var previous []string
+			if stored.AxfrExposed != "" {
+				previous = strings.Split(stored.AxfrExposed, ", ")
+			}
+			axfrExposed = strings.Join(transfers.ExposedKeeping(previous), ", ")
+		}
+		axfrExposed, resolved = lookupValue("AXFR", domain.Name, axfrExposed, stored.AxfrExposed, err)
+		if resolved {
+			database.UpdateAXFRExposed(domain.Name, axfrExposed)
 		}
-		database.UpdateSPF(domain.Name, spfRecord)
 
 		domainRec, err := database.GetDomainInfo(domain.Name)
 		if err != nil {
 			log.Println("Error geting info for domain Name:", domain.Name)
 		}
 
-		mapOfDates := dnsquery.GetAllDatesFromWhois(domain.Name)
+		mapOfDates := dnsquery.GetAllDatesFromWhoisContext(ctx, domain.Name)
 		for _, valor := range mapOfDates {
 			fmt.Println("Key Value:", valor)
 		}
@@ -96,63 +506,273 @@ func main() {
 		}
 
 		newDomainInfo := models.DomainInfo{
-			Name:        domainRec.Name,
-			Registrar:   domainRec.Registrar,
-			State:       domainRec.State,
-			Tier:        domainRec.Tier,
-			TransferTo:  domainRec.TransferTo,
-			LastCheck:   time.Now(),
-			Dmarc:       dmarcRecord,
-			Spf:         spfRecord,
-			Nameservers: nsRecordcomma,
-			Status:      true,
-			Whois:       whois,
+			Name:          domainRec.Name,
+			Registrar:     domainRec.Registrar,
+			State:         domainRec.State,
+			Tier:          domainRec.Tier,
+			TransferTo:    domainRec.TransferTo,
+			LastCheck:     time.Now(),
+			Dmarc:         dmarcRecord,
+			DmarcIssues:   dmarcIssues,
+			Spf:           spfRecord,
+			Nameservers:   nsRecordcomma,
+			Status:        true,
+			Whois:         whois,
+			Dnssec:        dnssecStatus,
+			Mx:            mxRecord,
+			ApexAddresses: apexAddress,
+			WwwAddresses:  wwwAddress,
+			Caa:           caaIssuers,
+			SpfLookups:    spfLookups,
+			SpfIssues:     spfIssues,
+			MtaStsId:      mtaStsId,
+			MtaStsMode:    mtaStsMode,
+			MtaStsMx:      mtaStsMx,
+			MtaStsIssues:  mtaStsIssues,
+			TlsRpt:        tlsRptRecord,
+			Bimi:          bimiRecord,
+			BimiIssues:    bimiIssues,
+			SoaMname:      soaMname,
+			SoaRname:      soaRname,
+			SoaSerial:     soaSerial,
+			SoaRefresh:    soaRefresh,
+			SoaRetry:      soaRetry,
+			SoaExpire:     soaExpire,
+			SoaMinimum:    soaMinimum,
+			Ttls:          ttlRecord,
+			TtlIssues:     ttlIssues,
+			AxfrExposed:   axfrExposed,
+			Wildcard:      wildcardRecord,
+			NameAscii:     nameAscii,
+			NameUnicode:   nameUnicode,
+		}
+
+		// Check that every authoritative nameserver serves the same zone
+		report, err := resolver.CheckAuthoritative(domain.Name)
+		if err != nil {
+			log.Printf("ERROR: Authoritative check failed for domain %s: %v", domain.Name, err)
+		} else if !report.Consistent() {
+			event := events.Event{
+				EventType:   events.EventTypeNameserverMismatch,
+				EventAction: events.EventActionAlert,
+				ExecuteTime: time.Now(),
+				DomainInfo:  newDomainInfo,
+				Details:     strings.Join(report.Issues, "\n"),
+			}
+			Observer.Notify(event)
+			log.Printf("Authoritative nameservers disagree for domain %s: %s", domain.Name, strings.Join(report.Issues, "; "))
 		}
 
+		if transfers != nil && len(transfers.Exposed()) > 0 {
+			event := events.Event{
+				EventType:   events.EventTypeAxfrExposed,
+				EventAction: events.EventActionAlert,
+				ExecuteTime: time.Now(),
+				DomainInfo:  newDomainInfo,
+				Details:     "Unauthenticated zone transfer allowed by: " + strings.Join(transfers.Exposed(), ", "),
+			}
+			Observer.Notify(event)
+			log.Printf("Zone transfer allowed for domain %s by %s", domain.Name, strings.Join(transfers.Exposed(), ", "))
+		}
+
+		checkDKIM(&Observer, resolver, newDomainInfo)
+		checkSubdomains(&Observer, resolver, newDomainInfo)
+
+		// Answers the consensus resolvers disagreed on during all the lookups above
+		var disagreementLines []string
+		for _, disagreement := range resolver.Disagreements() {
+			disagreementLines = append(disagreementLines, disagreement.String())
+		}
+		disagreements := strings.Join(disagreementLines, "\n")
+		database.UpdateResolverDisagreements(domain.Name, disagreements)
+		newDomainInfo.ResolverDisagreements = disagreements
+		if disagreements != "" {
+			event := events.Event{
+				EventType:   events.EventTypeResolverDisagreement,
+				EventAction: events.EventActionAlert,
+				ExecuteTime: time.Now(),
+				DomainInfo:  newDomainInfo,
+				Details:     disagreements,
+			}
+			Observer.Notify(event)
+			log.Printf("Resolvers disagree for domain %s: %s", domain.Name, strings.ReplaceAll(disagreements, "\n", "; "))
+		}
+
+		// Large answers, such as long TXT sets, that only fit over TCP
+		fallbacks := resolver.TCPFallbacks()
+		for _, fallback := range fallbacks {
+			log.Printf("Domain %s: %s needed TCP after a truncated reply", domain.Name, fallback)
+		}
+		tcpFallbacks := strings.Join(fallbacks, "\n")
+		database.UpdateTCPFallbacks(domain.Name, tcpFallbacks)
+		newDomainInfo.TcpFallbacks = tcpFallbacks
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Printf("WARNING: Lookups for domain %s exceeded %s, stored values were kept for the rest", domain.Name, domainTimeout)
+		}
+		cancel()
+
 		// Compare with stored data and create events for changes
 		if StorageErr == nil {
 			hasChanges := false
 
-			if dmarcRecord != domain_stored.Dmarc {
+			if detectChange(&Observer, events.EventTypeDmarc, "DMARC", "dmarc", newDomainInfo, *domain_stored, dmarcRecord, domain_stored.Dmarc) {
+				hasChanges = true
+			}
+
+			if detectChange(&Observer, events.EventTypeSpf, "SPF", "spf", newDomainInfo, *domain_stored, spfRecord, domain_stored.Spf) {
+				hasChanges = true
+			}
+
+			if detectChange(&Observer, events.EventTypeNameservers, "Nameservers", "nameservers", newDomainInfo, *domain_stored, nsRecordcomma, domain_stored.Nameservers) {
+				hasChanges = true
+			}
+
+			if detectChange(&Observer, events.EventTypeDnssec, "DNSSEC", "dnssec", newDomainInfo, *domain_stored, dnssecStatus, domain_stored.Dnssec) {
+				hasChanges = true
+			}
+
+			if detectChange(&Observer, events.EventTypeDmarcIssues, "DMARC validation", "dmarc_issues", newDomainInfo, *domain_stored, dmarcIssues, domain_stored.DmarcIssues) {
+				hasChanges = true
+			}
+
+			if detectChange(&Observer, events.EventTypeMx, "MX", "mx", newDomainInfo, *domain_stored, mxRecord, domain_stored.Mx) {
+				hasChanges = true
+			}
+
+			if detectChange(&Observer, events.EventTypeApexAddresses, "Apex address", "apex_addresses", newDomainInfo, *domain_stored, apexAddress, domain_stored.ApexAddresses) {
+				hasChanges = true
+			}
+
+			if detectChange(&Observer, events.EventTypeWwwAddresses, "www address", "www_addresses", newDomainInfo, *domain_stored, wwwAddress, domain_stored.WwwAddresses) {
+				hasChanges = true
+			}
+
+			if detectChange(&Observer, events.EventTypeCaa, "CAA", "caa", newDomainInfo, *domain_stored, caaIssuers, domain_stored.Caa) {
+				hasChanges = true
+			}
+
+			if detectChange(&Observer, events.EventTypeSpfIssues, "SPF validation", "spf_issues", newDomainInfo, *domain_stored, spfIssues, domain_stored.SpfIssues) {
+				hasChanges = true
+			}
+
+			if changes := mtaStsChanges(*domain_stored, newDomainInfo); changes != "" && domain_stored.Recorded("mta_sts_mode") {
 				event := events.Event{
-					EventType:      events.EventTypeDmarc,
+					EventType:      events.EventTypeMtaSts,
 					EventAction:    events.EventActionChange,
 					ExecuteTime:    time.Now(),
 					DomainInfo:     newDomainInfo,
 					DomainInfoPrev: *domain_stored,
+					Details:        changes,
 				}
 				Observer.Notify(event)
-				log.Printf("DMARC change detected for domain %s. Old: %s, New: %s", domain.Name, domain_stored.Dmarc, dmarcRecord)
-				// Here you would trigger your alert/notification system with the event
+				log.Printf("MTA-STS change detected for domain %s: %s", domain.Name, strings.ReplaceAll(changes, "\n", "; "))
 				hasChanges = true
 			}
 
-			if spfRecord != domain_stored.Spf {
+			if detectChange(&Observer, events.EventTypeMtaStsIssues, "MTA-STS validation", "mta_sts_issues", newDomainInfo, *domain_stored, mtaStsIssues, domain_stored.MtaStsIssues) {
+				hasChanges = true
+			}
+
+			if detectChange(&Observer, events.EventTypeTlsRpt, "TLS-RPT", "tls_rpt", newDomainInfo, *domain_stored, tlsRptRecord, domain_stored.TlsRpt) {
+				hasChanges = true
+			}
+
+			if detectChange(&Observer, events.EventTypeBimi, "BIMI", "bimi", newDomainInfo, *domain_stored, bimiRecord, domain_stored.Bimi) {
+				hasChanges = true
+			}
+
+			if detectChange(&Observer, events.EventTypeBimiIssues, "BIMI validation", "bimi_issues", newDomainInfo, *domain_stored, bimiIssues, domain_stored.BimiIssues) {
+				hasChanges = true
+			}
+
+			if changes := soaChanges(*domain_stored, newDomainInfo); changes != "" && domain_stored.Recorded("soa_mname") {
 				event := events.Event{
-					EventType:      events.EventTypeSpf,
+					EventType:      events.EventTypeSoa,
 					EventAction:    events.EventActionChange,
 					ExecuteTime:    time.Now(),
 					DomainInfo:     newDomainInfo,
 					DomainInfoPrev: *domain_stored,
+					Details:        changes,
+				}
+				Observer.Notify(event)
+				log.Printf("SOA change detected for domain %s: %s", domain.Name, strings.ReplaceAll(changes, "\n", "; "))
+				hasChanges = true
+			}
+
+			// A sharp TTL drop often precedes a migration, or a hijack
+			if drops := ttlPolicy.Drops(dnsquery.ParseTTLs(domain_stored.Ttls), dnsquery.ParseTTLs(ttlRecord)); len(drops) > 0 && domain_stored.Recorded("ttls") {
+				event := events.Event{
+					EventType:      events.EventTypeTtlDrop,
+					EventAction:    events.EventActionAlert,
+					ExecuteTime:    time.Now(),
+					DomainInfo:     newDomainInfo,
+					DomainInfoPrev: *domain_stored,
+					Details:        strings.Join(drops, "\n"),
 				}
 				Observer.Notify(event)
-				log.Printf("SPF change detected for domain %s. Old: %s, New: %s", domain.Name, domain_stored.Spf, spfRecord)
-				// Here you would trigger your alert/notification system with the event
+				log.Printf("TTL drop detected for domain %s: %s", domain.Name, strings.Join(drops, "; "))
+			}
+
+			if detectChange(&Observer, events.EventTypeTtls, "TTL", "ttls", newDomainInfo, *domain_stored, ttlRecord, domain_stored.Ttls) {
+				hasChanges = true
+			}
+
+			if detectChange(&Observer, events.EventTypeTtlIssues, "TTL validation", "ttl_issues", newDomainInfo, *domain_stored, ttlIssues, domain_stored.TtlIssues) {
 				hasChanges = true
 			}
 
-			if nsRecordcomma != domain_stored.Nameservers {
+			// A new serial means the zone was edited, which is worth an audit
+			// even when none of the records above changed
+			if soaSerial != domain_stored.SoaSerial && domain_stored.Recorded("soa_serial") {
+				details := fmt.Sprintf("SOA serial changed from %d to %d", domain_stored.SoaSerial, soaSerial)
+				if !hasChanges {
+					details += "\nNone of the monitored records changed, check the zone for edits to other records"
+				}
 				event := events.Event{
-					EventType:      events.EventTypeNameservers,
+					EventType:      events.EventTypeSoaSerial,
 					EventAction:    events.EventActionChange,
 					ExecuteTime:    time.Now(),
 					DomainInfo:     newDomainInfo,
 					DomainInfoPrev: *domain_stored,
+					Details:        details,
 				}
 				Observer.Notify(event)
+				log.Printf("SOA serial change detected for domain %s: %d -> %d", domain.Name, domain_stored.SoaSerial, soaSerial)
+				hasChanges = true
+			}
+
+			// A new wildcard gets its own alert since it silently makes subdomain
+			// checks and SPF lookups of random names succeed
+			if wildcardRecord != "" && domain_stored.Wildcard == "" && domain_stored.Recorded("wildcard") {
+				event := events.Event{
+					EventType:      events.EventTypeWildcardDetected,
+					EventAction:    events.EventActionAlert,
+					ExecuteTime:    time.Now(),
+					DomainInfo:     newDomainInfo,
+					DomainInfoPrev: *domain_stored,
+					Details:        "Any name under " + newDomainInfo.DisplayName() + " now resolves to:\n" + wildcardRecord,
+				}
+				Observer.Notify(event)
+				log.Printf("Wildcard detected for domain %s: %s", domain.Name, strings.ReplaceAll(wildcardRecord, "\n", "; "))
+				hasChanges = true
+			} else if detectChange(&Observer, events.EventTypeWildcard, "Wildcard", "wildcard", newDomainInfo, *domain_stored, wildcardRecord, domain_stored.Wildcard) {
+				hasChanges = true
+			}
+
+			// Exposure is alerted on every run, a change only needs a history entry
+			if axfrExposed != domain_stored.AxfrExposed {
+				log.Printf("AXFR exposure change detected for domain %s: %q -> %q", domain.Name, domain_stored.AxfrExposed, axfrExposed)
+				hasChanges = true
+			}
+
+			// Disagreements are alerted on every run, a change only needs a history entry
+			if disagreements != domain_stored.ResolverDisagreements {
+				hasChanges = true
+			}
 
-				log.Printf("Nameservers change detected for domain %s. Old: %s, New: %s", domain.Name, domain_stored.Nameservers, nsRecordcomma)
-				// Here you would trigger your alert/notification system with the event
+			// Columns added since the stored row was written get a baseline
+			// history entry, so they are compared from the next run on
+			if len(domain_stored.Unrecorded) > 0 {
 				hasChanges = true
 			}
 
@@ -172,4 +792,6 @@ func main() {
 		}
 	}
 
+	stats := baseResolver.CacheStats()
+	log.Printf("DNS cache: %d hits, %d misses, %d replies cached", stats.Hits, stats.Misses, stats.Entries)
 }
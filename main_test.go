@@ -0,0 +1,51 @@
+package main
+
+import (
+	"domain-tool-updater/dnsquery"
+	"domain-tool-updater/models"
+	"fmt"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestLookupValue(t *testing.T) {
+	lookupErr := func(kind dnsquery.ErrorKind) error {
+		return &dnsquery.LookupError{Kind: kind, Name: "example.com", Qtype: dns.TypeTXT}
+	}
+	cases := []struct {
+		name     string
+		err      error
+		want     string
+		resolved bool
+	}{
+		{"success", nil, "new", true},
+		{"NXDOMAIN", lookupErr(dnsquery.KindNXDomain), "", true},
+		{"NODATA", lookupErr(dnsquery.KindNoData), "", true},
+		{"SERVFAIL", lookupErr(dnsquery.KindServFail), "stored", false},
+		{"timeout", lookupErr(dnsquery.KindTimeout), "stored", false},
+		{"no consensus", lookupErr(dnsquery.KindNoConsensus), "stored", false},
+		{"truncated", lookupErr(dnsquery.KindTruncated), "stored", false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, resolved := lookupValue("TXT", "example.com", "new", "stored", c.err)
+			if got != c.want || resolved != c.resolved {
+				t.Fatalf("lookupValue = %q, %v, want %q, %v", got, resolved, c.want, c.resolved)
+			}
+		})
+	}
+}
+
+func TestMTASTSValues_PolicyHostFails(t *testing.T) {
+	stored := models.DomainInfo{MtaStsId: "1", MtaStsMode: "enforce", MtaStsMx: "mail.example.com", MtaStsIssues: "mx backup.example.org. is not covered"}
+	err := fmt.Errorf("%w: connection refused", dnsquery.ErrPolicyUnavailable)
+
+	id, mode, mx, issues, resolved := mtaStsValues("example.com", nil, err, nil, true, stored)
+	if resolved {
+		t.Fatalf("expected the stored policy to be kept")
+	}
+	if id != "1" || mode != "enforce" || mx != "mail.example.com" || issues != stored.MtaStsIssues {
+		t.Fatalf("expected stored policy, got %q %q %q %q", id, mode, mx, issues)
+	}
+}
@@ -4,15 +4,82 @@ import "time"
 
 // DomainInfo represents the structure you provided
 type DomainInfo struct {
-	Name        string
-	Registrar   string
-	State       string
-	Tier        string
-	TransferTo  string
-	LastCheck   time.Time
-	Spf         string
-	Dmarc       string
-	Nameservers string
-	Status      bool
-	Whois		string
-}
\ No newline at end of file
+	Name                  string
+	Registrar             string
+	State                 string
+	Tier                  string
+	TransferTo            string
+	LastCheck             time.Time
+	Spf                   string
+	Dmarc                 string
+	Nameservers           string
+	Status                bool
+	Whois                 string
+	Dnssec                string
+	Mx                    string
+	ApexAddresses         string
+	WwwAddresses          string
+	Caa                   string
+	SpfLookups            int
+	SpfIssues             string
+	DmarcIssues           string
+	MtaStsId              string
+	MtaStsMode            string
+	MtaStsMx              string
+	MtaStsIssues          string
+	TlsRpt                string
+	Bimi                  string
+	BimiIssues            string
+	SoaMname              string
+	SoaRname              string
+	SoaSerial             int
+	SoaRefresh            int
+	SoaRetry              int
+	SoaExpire             int
+	SoaMinimum            int
+	Ttls                  string
+	TtlIssues             string
+	AxfrExposed           string
+	ResolverDisagreements string
+	TcpFallbacks          string
+	NameAscii             string
+	NameUnicode           string
+	Wildcard              string
+	// Unrecorded holds the columns that were NULL when the domain was
+	// read, because they were added after the row was written.
+	Unrecorded map[string]bool
+}
+
+// Recorded reports whether column held a value when the domain was read.
+// Only columns added after the original schema can be unrecorded.
+func (d DomainInfo) Recorded(column string) bool {
+	return !d.Unrecorded[column]
+}
+
+// DisplayName returns the name to show in notifications, the Unicode form
+// of internationalized domain names when it is known
+func (d DomainInfo) DisplayName() string {
+	if d.NameUnicode != "" {
+		return d.NameUnicode
+	}
+	return d.Name
+}
+
+// DkimKey is the DKIM key published under one selector of a domain
+type DkimKey struct {
+	Domain    string
+	Selector  string
+	Record    string
+	KeyType   string
+	KeyBits   int
+	LastCheck time.Time
+}
+
+// CnameChain is the CNAME chain of one monitored subdomain of a domain
+type CnameChain struct {
+	Domain    string
+	Subdomain string
+	Chain     string
+	Issues    string
+	LastCheck time.Time
+}
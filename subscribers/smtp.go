@@ -4,6 +4,7 @@ import (
 	"domain-tool-updater/events"
 	"fmt"
 	"log"
+	"mime"
 	"net/smtp"
 	"os"
 	"strconv"
@@ -24,15 +25,104 @@ func (s *SmtpSubscriber) Update(event events.Event) {
 	case events.EventTypeNameservers:
 		domainInfo := event.GetDomainInfo()
 		domainInfoPrev := event.DomainInfoPrev
-		s.OnDomainChange(domainInfo.Name, domainInfoPrev.Nameservers, domainInfo.Nameservers)
+		s.OnDomainChange(domainInfo.DisplayName(), "Nameservers", domainInfoPrev.Nameservers, domainInfo.Nameservers)
 	case events.EventTypeDmarc:
 		domainInfo := event.GetDomainInfo()
 		domainInfoPrev := event.DomainInfoPrev
-		s.OnDomainChange(domainInfo.Name, domainInfoPrev.Dmarc, domainInfo.Dmarc)
+		s.OnDomainChangeDetails(domainInfo.DisplayName(), "DMARC", event.Details, domainInfoPrev.Dmarc, domainInfo.Dmarc)
 	case events.EventTypeSpf:
 		domainInfo := event.GetDomainInfo()
 		domainInfoPrev := event.DomainInfoPrev
-		s.OnDomainChange(domainInfo.Name, domainInfoPrev.Spf, domainInfo.Spf)
+		s.OnDomainChange(domainInfo.DisplayName(), "SPF", domainInfoPrev.Spf, domainInfo.Spf)
+	case events.EventTypeDnssec:
+		domainInfo := event.GetDomainInfo()
+		domainInfoPrev := event.DomainInfoPrev
+		s.OnDomainChange(domainInfo.DisplayName(), "DNSSEC", domainInfoPrev.Dnssec, domainInfo.Dnssec)
+	case events.EventTypeMx:
+		domainInfo := event.GetDomainInfo()
+		domainInfoPrev := event.DomainInfoPrev
+		s.OnDomainChange(domainInfo.DisplayName(), "MX", domainInfoPrev.Mx, domainInfo.Mx)
+	case events.EventTypeApexAddresses:
+		domainInfo := event.GetDomainInfo()
+		domainInfoPrev := event.DomainInfoPrev
+		s.OnDomainChange(domainInfo.DisplayName(), "Apex Addresses", domainInfoPrev.ApexAddresses, domainInfo.ApexAddresses)
+	case events.EventTypeWwwAddresses:
+		domainInfo := event.GetDomainInfo()
+		domainInfoPrev := event.DomainInfoPrev
+		s.OnDomainChange(domainInfo.DisplayName(), "www Addresses", domainInfoPrev.WwwAddresses, domainInfo.WwwAddresses)
+	case events.EventTypeCaa:
+		domainInfo := event.GetDomainInfo()
+		domainInfoPrev := event.DomainInfoPrev
+		s.OnDomainChange(domainInfo.DisplayName(), "CAA", domainInfoPrev.Caa, domainInfo.Caa)
+	case events.EventTypeSpfIssues:
+		domainInfo := event.GetDomainInfo()
+		domainInfoPrev := event.DomainInfoPrev
+		s.OnDomainChange(domainInfo.DisplayName(), "SPF Issues", domainInfoPrev.SpfIssues, domainInfo.SpfIssues)
+	case events.EventTypeDmarcIssues:
+		domainInfo := event.GetDomainInfo()
+		domainInfoPrev := event.DomainInfoPrev
+		s.OnDomainChange(domainInfo.DisplayName(), "DMARC Issues", domainInfoPrev.DmarcIssues, domainInfo.DmarcIssues)
+	case events.EventTypeMtaSts:
+		domainInfo := event.GetDomainInfo()
+		s.OnDomainAlert(domainInfo.DisplayName(), "MTA-STS Policy Change", event.Details)
+	case events.EventTypeMtaStsIssues:
+		domainInfo := event.GetDomainInfo()
+		domainInfoPrev := event.DomainInfoPrev
+		s.OnDomainChange(domainInfo.DisplayName(), "MTA-STS Issues", domainInfoPrev.MtaStsIssues, domainInfo.MtaStsIssues)
+	case events.EventTypeTlsRpt:
+		domainInfo := event.GetDomainInfo()
+		domainInfoPrev := event.DomainInfoPrev
+		s.OnDomainChange(domainInfo.DisplayName(), "TLS-RPT", domainInfoPrev.TlsRpt, domainInfo.TlsRpt)
+	case events.EventTypeBimi:
+		domainInfo := event.GetDomainInfo()
+		domainInfoPrev := event.DomainInfoPrev
+		s.OnDomainChange(domainInfo.DisplayName(), "BIMI", domainInfoPrev.Bimi, domainInfo.Bimi)
+	case events.EventTypeBimiIssues:
+		domainInfo := event.GetDomainInfo()
+		domainInfoPrev := event.DomainInfoPrev
+		s.OnDomainChange(domainInfo.DisplayName(), "BIMI Issues", domainInfoPrev.BimiIssues, domainInfo.BimiIssues)
+	case events.EventTypeSoa:
+		domainInfo := event.GetDomainInfo()
+		s.OnDomainAlert(domainInfo.DisplayName(), "SOA Change", event.Details)
+	case events.EventTypeSoaSerial:
+		domainInfo := event.GetDomainInfo()
+		s.OnDomainAlert(domainInfo.DisplayName(), "Zone Edited", event.Details)
+	case events.EventTypeTtls:
+		domainInfo := event.GetDomainInfo()
+		domainInfoPrev := event.DomainInfoPrev
+		s.OnDomainChange(domainInfo.DisplayName(), "TTLs", domainInfoPrev.Ttls, domainInfo.Ttls)
+	case events.EventTypeTtlIssues:
+		domainInfo := event.GetDomainInfo()
+		domainInfoPrev := event.DomainInfoPrev
+		s.OnDomainChange(domainInfo.DisplayName(), "TTL Issues", domainInfoPrev.TtlIssues, domainInfo.TtlIssues)
+	case events.EventTypeTtlDrop:
+		domainInfo := event.GetDomainInfo()
+		s.OnDomainAlert(domainInfo.DisplayName(), "TTL Drop", event.Details)
+	case events.EventTypeWildcard:
+		domainInfo := event.GetDomainInfo()
+		domainInfoPrev := event.DomainInfoPrev
+		s.OnDomainChange(domainInfo.DisplayName(), "Wildcard", domainInfoPrev.Wildcard, domainInfo.Wildcard)
+	case events.EventTypeWildcardDetected:
+		domainInfo := event.GetDomainInfo()
+		s.OnDomainAlert(domainInfo.DisplayName(), "Wildcard DNS Detected", event.Details)
+	case events.EventTypeNameserverMismatch:
+		domainInfo := event.GetDomainInfo()
+		s.OnDomainAlert(domainInfo.DisplayName(), "Nameserver Mismatch", event.Details)
+	case events.EventTypeAxfrExposed:
+		domainInfo := event.GetDomainInfo()
+		s.OnDomainAlert(domainInfo.DisplayName(), "Zone Transfer Allowed", event.Details)
+	case events.EventTypeResolverDisagreement:
+		domainInfo := event.GetDomainInfo()
+		s.OnDomainAlert(domainInfo.DisplayName(), "Resolver Disagreement", event.Details)
+	case events.EventTypeDkimAdded, events.EventTypeDkimRemoved, events.EventTypeDkimRotated:
+		domainInfo := event.GetDomainInfo()
+		s.OnDomainAlert(domainInfo.DisplayName(), "DKIM Key Change", event.Details)
+	case events.EventTypeCnameChain:
+		domainInfo := event.GetDomainInfo()
+		s.OnDomainAlert(domainInfo.DisplayName(), "CNAME Chain Change", event.Details)
+	case events.EventTypeDanglingCname:
+		domainInfo := event.GetDomainInfo()
+		s.OnDomainAlert(domainInfo.DisplayName(), "Dangling CNAME Detected", event.Details)
 	}
 }
 
@@ -61,9 +151,20 @@ func NewSmtpSubscriber() *SmtpSubscriber {
 	}
 }
 
-func (s *SmtpSubscriber) OnDomainChange(domain, oldStatus, newStatus string) {
-	msg := fmt.Sprintf("Subject: Domain Status Change\r\n\r\nDomain: %s\nOld Status: %s\nNew Status: %s",
-		domain, oldStatus, newStatus)
+// OnDomainChange sends a change notification for one record of domain,
+// named in the subject.
+func (s *SmtpSubscriber) OnDomainChange(domain, record, oldStatus, newStatus string) {
+	s.OnDomainChangeDetails(domain, record, "", oldStatus, newStatus)
+}
+
+// OnDomainChangeDetails sends a change notification that starts with a
+// description of the change, when there is one.
+func (s *SmtpSubscriber) OnDomainChangeDetails(domain, record, details, oldStatus, newStatus string) {
+	if details != "" {
+		details += "\n\n"
+	}
+	msg := mailMessage("Domain Status Change: "+record, fmt.Sprintf("Domain: %s\nRecord: %s\n%sOld Status: %s\nNew Status: %s",
+		domain, record, details, oldStatus, newStatus))
 
 	auth := smtp.PlainAuth("", s.smtpUser, s.smtpPassword, s.smtpHost)
 	addr := fmt.Sprintf("%s:%d", s.smtpHost, s.smtpPort)
@@ -73,3 +174,25 @@ func (s *SmtpSubscriber) OnDomainChange(domain, oldStatus, newStatus string) {
 		log.Printf("Failed to send email notification: %v", err)
 	}
 }
+
+func (s *SmtpSubscriber) OnDomainAlert(domain, subject, details string) {
+	msg := mailMessage("Domain Alert: "+subject, fmt.Sprintf("Domain: %s\n%s", domain, details))
+
+	auth := smtp.PlainAuth("", s.smtpUser, s.smtpPassword, s.smtpHost)
+	addr := fmt.Sprintf("%s:%d", s.smtpHost, s.smtpPort)
+
+	err := smtp.SendMail(addr, auth, s.fromEmail, []string{s.toEmail}, []byte(msg))
+	if err != nil {
+		log.Printf("Failed to send email notification: %v", err)
+	}
+}
+
+// mailMessage builds a plain text UTF-8 message. Domain names are shown in
+// Unicode form, so the subject is RFC 2047 encoded when it is not ASCII.
+func mailMessage(subject, body string) string {
+	return "MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"Content-Transfer-Encoding: 8bit\r\n" +
+		"Subject: " + mime.QEncoding.Encode("UTF-8", subject) + "\r\n\r\n" +
+		body
+}